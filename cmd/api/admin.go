@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) adminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		search   string
+		role     string
+		isActive *bool
+		models.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.search = app.readString(qs, "q", "")
+	input.role = app.readString(qs, "role", "")
+	if qs.Has("is_active") {
+		active := app.readBool(qs, "is_active", false, v)
+		input.isActive = &active
+	}
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Sort = app.readString(qs, "sort", "id")
+	input.SortSafeList = []string{"id", "email", "created_at", "-id", "-email", "-created_at"}
+
+	v.Check(input.role != "" && !slices.Contains(models.UserRoles, input.role), "role", "unknown role")
+	if models.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	users, metadata, err := app.models.Users.GetAll(input.search, input.role, input.isActive, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) adminShowUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	permissions, err := app.userPermissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	roles, err := app.models.Roles.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	mfaEnabled, err := app.models.MFA.IsEnabled(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"user": user, "roles": roles, "permissions": permissions, "mfa_enabled": mfaEnabled}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) adminUpdateUserStatusHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		IsActive *bool `json:"is_active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.IsActive == nil, "is_active", "must be provided")
+	v.Check(user.ID == app.getUserContext(r).ID, "is_active", "you can't change the status of your own account")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// locking an admin out takes the same permission as taking the admin role away
+	if user.Role == "admin" {
+		permitted, err := app.hasPermission(r, "admin:permissions:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permitted {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	err = app.models.Users.SetActive(user.ID, *input.IsActive)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.noUserFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// a deactivated user is logged out, otherwise the cached user is just outdated
+	if !*input.IsActive {
+		err = app.revokeSessions(r.Context(), user.ID)
+	} else {
+		err = app.forgetUser(r.Context(), user.ID)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user.IsActive = *input.IsActive
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) adminUpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(!slices.Contains(models.UserRoles, input.Role), "role", "role must be customer, seller or admin")
+	v.Check(user.ID == app.getUserContext(r).ID, "role", "you can't change the role of your own account")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// the admin role carries the admin permissions, so only those who may grant
+	// permissions can hand it out or take it away
+	if input.Role == "admin" || user.Role == "admin" {
+		permitted, err := app.hasPermission(r, "admin:permissions:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permitted {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	err = app.models.Roles.ReplaceForUser(user.ID, user.Role, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.noUserFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.forgetUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user.Role = input.Role
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) adminGrantPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserPermissions(w, r, app.models.Permissions.AddForUser)
+}
+
+func (app *application) adminRevokePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserPermissions(w, r, app.models.Permissions.RemoveForUser)
+}
+
+// changeUserPermissions reads the permission codes of the request, checks
+// they exist and applies change to the user in the :id path parameter.
+func (app *application) changeUserPermissions(w http.ResponseWriter, r *http.Request, change func(int64, ...string) error) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Codes []string `json:"codes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Codes) == 0, "codes", "at least one permission code must be provided")
+	for _, code := range input.Codes {
+		v.Check(!known.Include(code), "codes", "unknown permission "+code)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = change(user.ID, input.Codes...)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrPermissionFromRole):
+			v.AddError("codes", err.Error())
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.forgetPermissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions, err := app.userPermissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) adminListPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	permissions, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) adminLogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.revokeSessions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "all sessions of the user have been logged out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// readAdminUser loads the user in the :id path parameter for the admin endpoints.
+func (app *application) readAdminUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.noUserFound(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.GetUser(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.noUserFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+
+}
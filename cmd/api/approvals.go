@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+// adminListRestaurantsHandler lists the restaurants of every review status, the
+// review queue by default.
+func (app *application) adminListRestaurantsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		name         string
+		reviewStatus string
+		models.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.name = app.readString(qs, "name", "")
+	input.reviewStatus = app.readString(qs, "review_status", models.RestaurantPendingReview)
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Sort = app.readString(qs, "sort", "id")
+	input.SortSafeList = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	v.Check(!validator.PermittedValue(input.reviewStatus, models.RestaurantPendingReview, models.RestaurantApproved, models.RestaurantRejected), "review_status", "review_status must be pending_review, approved or rejected")
+	if models.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	restaurants, metadata, err := app.models.Restaurants.GetAll(input.name, false, input.reviewStatus, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if restaurants == nil {
+		restaurants = []*models.Restaurant{}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"restaurants": restaurants, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// adminReviewRestaurantHandler approves or rejects a restaurant and lets its
+// owners know about the decision.
+func (app *application) adminReviewRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		Status string  `json:"status"`
+		Reason *string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Reason != nil {
+		reason := strings.TrimSpace(*input.Reason)
+		input.Reason = &reason
+	}
+
+	v := validator.New()
+
+	if models.ValidateRestaurantReview(v, input.Status, input.Reason); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	restaurant, err := app.models.Restaurants.Get(restaurantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	restaurant.ReviewStatus = input.Status
+	restaurant.ReviewReason = input.Reason
+
+	err = app.models.Restaurants.Review(restaurant)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	members, err := app.models.Staff.GetAll(restaurant.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// sellers only get restaurant:write once one of their restaurants is approved
+	if restaurant.ReviewStatus == models.RestaurantApproved {
+		owners := []int64{}
+		for _, member := range members {
+			if member.Role == models.StaffOwner {
+				owners = append(owners, member.UserID)
+			}
+		}
+
+		for _, id := range owners {
+			err = app.models.Permissions.AddForUser(id, "restaurant:write")
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		err = app.forgetPermissions(r.Context(), owners...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	reason := ""
+	if restaurant.ReviewReason != nil {
+		reason = *restaurant.ReviewReason
+	}
+
+	for _, member := range members {
+		if member.Role != models.StaffOwner {
+			continue
+		}
+
+		app.background(func() {
+			err := app.mailer.Send(member.Email, "restaurant_review.tmpl", map[string]any{
+				"firstName":      member.FirstName,
+				"restaurantName": restaurant.Name,
+				"approved":       restaurant.ReviewStatus == models.RestaurantApproved,
+				"reason":         reason,
+			})
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"restaurant": restaurant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
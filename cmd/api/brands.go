@@ -0,0 +1,629 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) createBrandHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	brand := &models.Brand{Name: strings.TrimSpace(input.Name)}
+
+	v := validator.New()
+
+	if models.ValidateBrand(v, *brand); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Brands.Insert(brand, app.getUserContext(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateBrandName):
+			v.AddError("name", "a brand with this name already exists")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"brand": brand}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) showBrandHandler(w http.ResponseWriter, r *http.Request) {
+
+	brand, ok := app.readBrand(w, r)
+	if !ok {
+		return
+	}
+
+	branches, err := app.models.Brands.GetBranches(brand.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"brand": brand, "restaurants": branches}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateBrandHandler(w http.ResponseWriter, r *http.Request) {
+
+	brand, ok := app.readBrand(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name *string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		brand.Name = strings.TrimSpace(*input.Name)
+	}
+
+	v := validator.New()
+
+	if models.ValidateBrand(v, *brand); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Brands.Update(brand)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateBrandName):
+			v.AddError("name", "a brand with this name already exists")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"brand": brand}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) addBranchHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		RestaurantID int64 `json:"restaurant_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.RestaurantID < 1, "restaurant_id", "a valid restaurant id must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// only the owners of the restaurant can hand it over to a brand
+	owner, err := app.worksAt(app.getUserContext(r), input.RestaurantID, models.StaffOwner)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !owner {
+		app.notRestaurantOwnerResponse(w, r)
+		return
+	}
+
+	err = app.models.Brands.AddBranch(brandID, input.RestaurantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantHasBrand):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	restaurant, err := app.models.Restaurants.Get(input.RestaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"restaurant": restaurant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) removeBranchHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	restaurantID, err := app.readNamedIDParam(r, "restaurant_id")
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	err = app.models.Brands.RemoveBranch(brandID, restaurantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "restaurant successfully removed from the brand"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listBrandMembersHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	members, err := app.models.Brands.GetMembers(brandID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"members": members}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateBrandMemberHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userID, err := app.readNamedIDParam(r, "user_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(!validator.PermittedValue(input.Role, models.BrandRoles...), "role", "role must be owner or manager"); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Brands.UpdateMember(brandID, userID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	members, err := app.models.Brands.GetMembers(brandID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"members": members}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// createBrandInvitationHandler invites an email address to the members of the
+// brand, the invitation is accepted like the one to the staff of a restaurant.
+func (app *application) createBrandInvitationHandler(w http.ResponseWriter, r *http.Request) {
+
+	brand, ok := app.readBrand(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	invitation := &models.Invitation{
+		BrandID:   &brand.ID,
+		Email:     strings.TrimSpace(input.Email),
+		Role:      input.Role,
+		InvitedBy: user.ID,
+	}
+
+	v := validator.New()
+
+	if models.ValidateInvitation(v, *invitation); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	token, err := models.GenerateToken(invitationTTL, user.ID, models.InvitationScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Staff.Invite(invitation, token)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		err := app.mailer.Send(invitation.Email, "staff_invitation.tmpl", map[string]any{
+			"inviterName":     user.FirstName,
+			"restaurantName":  brand.Name,
+			"role":            invitation.Role,
+			"invitationToken": token.PlainToken,
+		})
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listBrandInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	invitations, err := app.models.Staff.GetBrandInvitations(brandID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"invitations": invitations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) revokeBrandInvitationHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	invitationID, err := app.readNamedIDParam(r, "invitation_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Staff.RevokeBrandInvitation(brandID, invitationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "invitation successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) removeBrandMemberHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userID, err := app.readNamedIDParam(r, "user_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// everyone can leave, others are removed by the owners
+	if userID != app.getUserContext(r).ID {
+		owner, err := app.inBrand(app.getUserContext(r), brandID, models.StaffOwner)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !owner {
+			app.errorResponse(w, r, http.StatusForbidden, "only owners can remove brand members")
+			return
+		}
+	}
+
+	err = app.models.Brands.RemoveMember(brandID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.dropStaffRole(r.Context(), userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "member successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) createBrandCategoryHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	category := models.Category{
+		BrandID: &brandID,
+		Name:    input.Name,
+	}
+
+	if models.ValidateCategory(v, category); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	ok := app.models.Categories.CategoryExists(category)
+	if ok {
+		v.AddError("name", "this brand has already created this category")
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Categories.Insert(&category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"category": category}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// setMenuOverrideHandler changes the price or the availability of a brand menu
+// item at one of the brand's branches.
+func (app *application) setMenuOverrideHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	menuID, err := app.readNamedIDParam(r, "menu_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		PriceCent   *int64 `json:"price_cent"`
+		IsAvailable *bool  `json:"is_available"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	override := &models.MenuOverride{
+		RestaurantID: restaurantID,
+		MenuID:       menuID,
+		PriceCent:    input.PriceCent,
+		IsAvailable:  input.IsAvailable,
+	}
+
+	v := validator.New()
+
+	if models.ValidateMenuOverride(v, *override); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Menu.SetOverride(override)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusNotFound, "no menu of the restaurant's brand found with this ID")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"override": override}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteMenuOverrideHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	menuID, err := app.readNamedIDParam(r, "menu_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Menu.DeleteOverride(restaurantID, menuID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "the brand's price and availability apply again"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) readBrand(w http.ResponseWriter, r *http.Request) (*models.Brand, bool) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	brand, err := app.models.Brands.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return brand, true
+
+}
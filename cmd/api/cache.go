@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/jwt"
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// userCacheTTL is how long a user and the user's permissions stay cached.
+const userCacheTTL = 24 * time.Hour
+
+// permissionCache counts the permission lookups answered from redis ("hits")
+// and the ones that went to the database ("misses").
+var permissionCache = expvar.NewMap("permission_cache")
+
+func userKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// userTokensKey is the redis set holding the token: keys cached for a user, so
+// they can all be dropped when the user's sessions are revoked.
+func userTokensKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10) + ":tokens"
+}
+
+// cacheToken caches the token and the user behind it for the authenticate middleware.
+func (app *application) cacheToken(ctx context.Context, token *models.Token, user *models.User) {
+
+	ttl := time.Until(token.Expiry)
+
+	err := app.redis.Set(ctx, "token:"+token.PlainToken, user.ID, ttl).Err()
+	if err != nil {
+		app.logger.Error("failed to cache user id", "Error", err)
+	}
+
+	tokensKey := userTokensKey(user.ID)
+
+	err = app.redis.SAdd(ctx, tokensKey, "token:"+token.PlainToken).Err()
+	if err != nil {
+		app.logger.Error("failed to index cached token", "Error", err)
+	} else {
+		// the set lives as long as the newest token
+		app.redis.Expire(ctx, tokensKey, ttl)
+	}
+
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		app.logger.Error("failed to encode user", "Error", err)
+		return
+	}
+
+	err = app.redis.Set(ctx, userKey(user.ID), userBytes, userCacheTTL).Err()
+	if err != nil {
+		app.logger.Error("failed to cache user", "Error", err)
+	}
+
+}
+
+// cachedUser returns the user from redis, it is loaded from the database and
+// cached when missing.
+func (app *application) cachedUser(ctx context.Context, userID int64) (*models.User, error) {
+
+	cached, err := app.redis.Get(ctx, userKey(userID)).Bytes()
+	if err == nil {
+		var user models.User
+
+		err = json.Unmarshal(cached, &user)
+		if err == nil {
+			return &user, nil
+		}
+	}
+
+	user, err := app.models.Users.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userBytes, err := json.Marshal(user)
+	if err == nil {
+		err = app.redis.Set(ctx, userKey(user.ID), userBytes, userCacheTTL).Err()
+	}
+	if err != nil {
+		app.logger.Error("failed to cache user", "Error", err)
+	}
+
+	return user, nil
+
+}
+
+// purgeUserCache drops every cached token of the user together with the cached user.
+func (app *application) purgeUserCache(ctx context.Context, userID int64) error {
+
+	tokensKey := userTokensKey(userID)
+
+	keys, err := app.redis.SMembers(ctx, tokensKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys = append(keys, tokensKey, userKey(userID), permissionsKey(userID))
+
+	return app.redis.Del(ctx, keys...).Err()
+
+}
+
+// touchSession records the use of an authentication token, at most once a minute
+// per token so busy clients don't turn every request into a write.
+func (app *application) touchSession(ctx context.Context, token string) {
+
+	fresh, err := app.redis.SetNX(ctx, "token_used:"+token, 1, time.Minute).Result()
+	if err != nil || !fresh {
+		return
+	}
+
+	app.background(func() {
+		err := app.models.Tokens.Touch(token)
+		if err != nil {
+			app.logger.Error("failed to record token use", "Error", err)
+		}
+	})
+
+}
+
+func permissionsKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10) + ":permissions"
+}
+
+// userPermissions returns the permissions of the user from redis, they are
+// loaded from the database and cached when missing.
+func (app *application) userPermissions(ctx context.Context, userID int64) (models.Permissions, error) {
+
+	cached, err := app.redis.Get(ctx, permissionsKey(userID)).Bytes()
+	if err == nil {
+		var permissions models.Permissions
+
+		err = json.Unmarshal(cached, &permissions)
+		if err == nil {
+			permissionCache.Add("hits", 1)
+			return permissions, nil
+		}
+	}
+
+	permissionCache.Add("misses", 1)
+
+	permissions, err := app.models.Permissions.GetForAllUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if permissions == nil {
+		permissions = models.Permissions{}
+	}
+
+	permissionsBytes, err := json.Marshal(permissions)
+	if err == nil {
+		err = app.redis.Set(ctx, permissionsKey(userID), permissionsBytes, userCacheTTL).Err()
+	}
+	if err != nil {
+		app.logger.Error("failed to cache permissions", "Error", err)
+	}
+
+	return permissions, nil
+
+}
+
+// forgetPermissions drops the cached permissions of the users after a change
+// of their permissions or roles, their signed tokens have to be refreshed.
+func (app *application) forgetPermissions(ctx context.Context, userIDs ...int64) error {
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = permissionsKey(userID)
+	}
+
+	// a role can have many holders, everything goes in one round trip
+	pipe := app.redis.TxPipeline()
+	pipe.Del(ctx, keys...)
+
+	if app.jwt != nil {
+		now := time.Now().Unix()
+		for _, userID := range userIDs {
+			pipe.Set(ctx, revokedAtKey(userID), now, app.cfg.Auth.AccessTokenTTL)
+		}
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+
+}
+
+// forgetUser drops the cached user and permissions after a change of the user,
+// the sessions stay valid but signed tokens have to be refreshed.
+func (app *application) forgetUser(ctx context.Context, userID int64) error {
+	err := app.redis.Del(ctx, userKey(userID), permissionsKey(userID)).Err()
+	if err != nil {
+		return err
+	}
+
+	return app.revokeSignedTokens(ctx, userID)
+}
+
+// revokedAtKey holds when the signed tokens of the user were last revoked.
+func revokedAtKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10) + ":revoked_at"
+}
+
+func sessionRevokedKey(sessionID string) string {
+	return "session:" + sessionID + ":revoked"
+}
+
+// revokeSignedTokens refuses the signed tokens issued to the user until now,
+// they carry the user's role and permissions and can't be purged from the cache.
+// The marker only has to outlive the longest access token.
+func (app *application) revokeSignedTokens(ctx context.Context, userID int64) error {
+
+	if app.jwt == nil {
+		return nil
+	}
+
+	return app.redis.Set(ctx, revokedAtKey(userID), time.Now().Unix(), app.cfg.Auth.AccessTokenTTL).Err()
+
+}
+
+// revokeSignedSession refuses the signed tokens of one session after a logout.
+func (app *application) revokeSignedSession(ctx context.Context, sessionID string) error {
+	return app.redis.Set(ctx, sessionRevokedKey(sessionID), 1, app.cfg.Auth.AccessTokenTTL).Err()
+}
+
+// signedTokenRevoked reports whether the token was issued before the user's
+// tokens were revoked, a token of the same second counts as revoked.
+func (app *application) signedTokenRevoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+
+	revokedAt, err := app.redis.Get(ctx, revokedAtKey(claims.Subject)).Int64()
+	switch {
+	case err == nil:
+		if claims.IssuedAt <= revokedAt {
+			return true, nil
+		}
+	case !errors.Is(err, redis.Nil):
+		return false, err
+	}
+
+	if claims.SessionID == "" {
+		return false, nil
+	}
+
+	n, err := app.redis.Exists(ctx, sessionRevokedKey(claims.SessionID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/payments"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) showCartHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := app.getUserContext(r)
+
+	items, err := app.models.Carts.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"cart": items, "total_cent": models.CartTotal(items)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) addCartItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		MenuID       int64   `json:"menu_id"`
+		RestaurantID int64   `json:"restaurant_id"`
+		Options      []int64 `json:"options"`
+		Quantity     int     `json:"quantity"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.MenuID < 1, "menu_id", "a valid menu id must be provided")
+	if models.ValidateCartQuantity(v, input.Quantity); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	groups, err := app.models.OptionGroups.GetAllForMenu(input.MenuID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if models.ValidateOptionSelection(v, groups, input.Options); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	err = app.models.Carts.AddItem(user.ID, input.MenuID, input.RestaurantID, input.Options, input.Quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("menu_id", "no menu found with this ID")
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, models.ErrMixedRestaurantCart):
+			v.AddError("menu_id", err.Error())
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, models.ErrCartQuantityExceeded):
+			v.AddError("quantity", err.Error())
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, models.ErrBranchRequired):
+			v.AddError("restaurant_id", err.Error())
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	items, err := app.models.Carts.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"cart": items, "total_cent": models.CartTotal(items)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) removeCartItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	itemID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	err = app.models.Carts.RemoveItem(user.ID, itemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "item removed from cart"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) clearCartHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := app.getUserContext(r)
+
+	err := app.models.Carts.Clear(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "cart cleared"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) checkoutHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(v.Empty(input.PaymentMethod), "payment_method", "payment method must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	items, err := app.models.Carts.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(items) > 0 {
+		open, err := app.models.Hours.IsOpenNow(items[0].RestaurantID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !open {
+			v.AddError("cart", "the restaurant is closed right now")
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+	}
+
+	order, err := app.models.Orders.CreateFromCart(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEmptyCart), errors.Is(err, models.ErrMenuUnavailable), errors.Is(err, models.ErrMixedRestaurantCart):
+			v.AddError("cart", err.Error())
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the order only becomes visible to the restaurant once the payment is authorized
+	payment, err := app.authorizePayment(order, input.PaymentMethod)
+	if err != nil {
+		if payment == nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		updateErr := app.models.Orders.UpdateStatus(order, models.OrderStatusCancelled)
+		if updateErr != nil {
+			app.logError(r, updateErr)
+		}
+
+		switch {
+		case errors.Is(err, payments.ErrDeclined), errors.Is(err, payments.ErrInvalidAmount):
+			app.paymentFailedResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Orders.UpdateStatus(order, models.OrderStatusPlaced)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Carts.Clear(user.ID)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	app.publishOrder(order)
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"order": order, "payment": payment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
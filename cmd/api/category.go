@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -8,14 +9,17 @@ import (
 	"github.com/geekilx/restaurantAPI/internal/validator"
 )
 
+// createCategoryHandler creates a category of the restaurant_id in the body,
+// the seller's own restaurant when it's left out.
 func (app *application) createCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name string `json:"name"`
+		Name         string `json:"name"`
+		RestaurantID *int64 `json:"restaurant_id"`
 	}
 
 	err := app.readJSON(w, r, &input)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
@@ -23,11 +27,32 @@ func (app *application) createCategoryHandler(w http.ResponseWriter, r *http.Req
 
 	user := app.getUserContext(r)
 	category := models.Category{
-		RestaurantID: *user.RestaurantID,
+		RestaurantID: user.RestaurantID,
 		Name:         input.Name,
 	}
 
-	ok := app.models.Categories.CategoryExists(category.Name, *user.RestaurantID)
+	if input.RestaurantID != nil {
+		category.RestaurantID = input.RestaurantID
+	}
+
+	v.Check(category.RestaurantID == nil, "restaurant_id", "restaurant_id must be provided")
+	if models.ValidateCategory(v, category); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	manager, err := app.managesCategory(user, &category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !manager {
+		app.notRestaurantOwnerResponse(w, r)
+		return
+	}
+
+	ok := app.models.Categories.CategoryExists(category)
 	if ok {
 		v.AddError("name", "this restaurant has already created this category")
 		app.failedValidationResponse(w, r, v)
@@ -85,6 +110,10 @@ func (app *application) showSpecificRestaurantCategory(w http.ResponseWriter, r
 	}
 	fmt.Println(restID)
 
+	if _, ok := app.readVisibleRestaurant(w, r, restID); !ok {
+		return
+	}
+
 	catgeories, err := app.models.Categories.GetAllForRestaurant(restID)
 	if err != nil || catgeories == nil {
 		app.noCategoryIsAvailable(w, r)
@@ -105,6 +134,21 @@ func (app *application) allMenuForCategoryHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	category, err := app.models.Categories.Get(catID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.noMenuAvailable(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.categoryVisible(w, r, category) {
+		return
+	}
+
 	menus, err := app.models.Menu.GetAllMenuForCategory(catID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -122,3 +166,204 @@ func (app *application) allMenuForCategoryHandler(w http.ResponseWriter, r *http
 	}
 
 }
+
+func (app *application) updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+
+	category, ok := app.readOwnedCategory(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name *string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Name != nil && *input.Name != category.Name {
+		category.Name = *input.Name
+
+		if models.ValidateCategory(v, *category); !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+
+		if app.models.Categories.CategoryExists(*category) {
+			v.AddError("name", "this restaurant has already created this category")
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+	}
+
+	err = app.models.Categories.Update(category)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"category": category}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+
+	category, ok := app.readOwnedCategory(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Categories.Delete(category.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "the category and its menu were deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// categoryVisible answers with a not found when the category belongs to a
+// restaurant the user can't see. Brand categories are shared by every branch.
+func (app *application) categoryVisible(w http.ResponseWriter, r *http.Request, category *models.Category) bool {
+
+	if category.RestaurantID == nil {
+		return true
+	}
+
+	_, ok := app.readVisibleRestaurant(w, r, *category.RestaurantID)
+	return ok
+
+}
+
+func (app *application) reorderRestaurantCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	app.reorder(w, r, restaurantID, app.models.Categories.ReorderForRestaurant)
+
+}
+
+func (app *application) reorderBrandCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	brandID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.reorder(w, r, brandID, app.models.Categories.ReorderForBrand)
+
+}
+
+func (app *application) reorderMenuHandler(w http.ResponseWriter, r *http.Request) {
+
+	category, ok := app.readOwnedCategory(w, r)
+	if !ok {
+		return
+	}
+
+	app.reorder(w, r, category.ID, app.models.Menu.Reorder)
+
+}
+
+// reorder applies the order of the ids in the request to the categories or the
+// menu items of scopeID.
+func (app *application) reorder(w http.ResponseWriter, r *http.Request, scopeID int64, apply func(int64, []int64) error) {
+
+	var input struct {
+		IDs []int64 `json:"ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidatePositions(v, input.IDs); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = apply(scopeID, input.IDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidOrder):
+			v.AddError("ids", err.Error())
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"ids": input.IDs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// readOwnedCategory reads the category of the route and checks that it belongs
+// to a restaurant or a brand the seller manages.
+func (app *application) readOwnedCategory(w http.ResponseWriter, r *http.Request) (*models.Category, bool) {
+
+	categoryID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	category, err := app.models.Categories.Get(categoryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	manager, err := app.managesCategory(app.getUserContext(r), category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !manager {
+		app.notRestaurantOwnerResponse(w, r)
+		return nil, false
+	}
+
+	return category, true
+
+}
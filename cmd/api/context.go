@@ -4,12 +4,14 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/geekilx/restaurantAPI/internal/jwt"
 	"github.com/geekilx/restaurantAPI/internal/models"
 )
 
 type contextKey string
 
 const userContextKey = contextKey("user")
+const claimsContextKey = contextKey("claims")
 
 func (app *application) getUserContext(r *http.Request) *models.User {
 
@@ -28,3 +30,20 @@ func (app *application) setUserContext(w http.ResponseWriter, r *http.Request, u
 	return r.WithContext(ctx)
 
 }
+
+// getClaimsContext returns the claims of the signed token the request was
+// authenticated with, or nil for an opaque token.
+func (app *application) getClaimsContext(r *http.Request) *jwt.Claims {
+
+	claims, _ := r.Context().Value(claimsContextKey).(*jwt.Claims)
+
+	return claims
+
+}
+
+func (app *application) setClaimsContext(r *http.Request, claims *jwt.Claims) *http.Request {
+	ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+
+	return r.WithContext(ctx)
+
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/geekilx/restaurantAPI/internal/validator"
 )
@@ -64,7 +68,16 @@ func (app *application) noRestaurantFound(w http.ResponseWriter, r *http.Request
 func (app *application) invalidUserCredintails(w http.ResponseWriter, r *http.Request) {
 	message := "invalid credintails for this user"
 
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) loginThrottledResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	message := fmt.Sprintf("too many failed login attempts, try again in %d seconds", seconds)
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
@@ -124,3 +137,53 @@ func (app *application) invalidPasswordForUser(w http.ResponseWriter, r *http.Re
 
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
 }
+
+func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+}
+
+func (app *application) invalidOrderTransitionResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusConflict, err.Error())
+}
+
+func (app *application) notRestaurantOwnerResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you can only manage your own restaurant"
+
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) notBrandManagerResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you can only manage your own brand"
+
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) paymentFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	message := "the payment could not be authorized: " + err.Error()
+
+	app.errorResponse(w, r, http.StatusPaymentRequired, message)
+}
+
+func (app *application) paymentCaptureFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the payment for this order could not be captured"
+
+	app.errorResponse(w, r, http.StatusBadGateway, message)
+}
+
+func (app *application) paymentVoidFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the order was cancelled but its payment could not be released"
+
+	app.errorResponse(w, r, http.StatusBadGateway, message)
+}
+
+func (app *application) invalidMFACodeResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid two-factor authentication code"
+
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) mfaRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you have to enable two-factor authentication to access this resource"
+
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
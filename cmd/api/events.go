@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+)
+
+// orderEvent is what gets published on redis and pushed to the SSE clients
+// every time an order changes status.
+type orderEvent struct {
+	OrderID      int64     `json:"order_id"`
+	RestaurantID int64     `json:"restaurant_id"`
+	Status       string    `json:"status"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func orderChannel(orderID int64) string {
+	return "orders:" + strconv.FormatInt(orderID, 10)
+}
+
+func restaurantOrdersChannel(restaurantID int64) string {
+	return "restaurant:" + strconv.FormatInt(restaurantID, 10) + ":orders"
+}
+
+// publishOrderEvent sends the order status to every API instance through redis
+// pub/sub, so subscribers connected to another replica get it as well.
+func (app *application) publishOrderEvent(event orderEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		app.logger.Error("failed to marshal order event", "Error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for _, channel := range []string{orderChannel(event.OrderID), restaurantOrdersChannel(event.RestaurantID)} {
+		err = app.redis.Publish(ctx, channel, payload).Err()
+		if err != nil {
+			app.logger.Error("failed to publish order event", "channel", channel, "Error", err)
+		}
+	}
+}
+
+func (app *application) publishOrder(order *models.Order) {
+	app.publishOrderEvent(orderEvent{
+		OrderID:      order.ID,
+		RestaurantID: order.RestaurantID,
+		Status:       order.Status,
+		UpdatedAt:    order.UpdatedAt,
+	})
+}
+
+// streamEvents subscribes to the redis channel and writes every message to the
+// client as a server-sent event until the client goes away or the server shuts down.
+func (app *application) streamEvents(w http.ResponseWriter, r *http.Request, channel string, initial any) {
+
+	rc := http.NewResponseController(w)
+
+	// the server WriteTimeout would otherwise close the stream after a few seconds
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	sub := app.redis.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	// wait for the subscription to be confirmed so no event is missed after the initial state
+	_, err = sub.Receive(ctx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if initial != nil {
+		data, err := json.Marshal(initial)
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+		fmt.Fprintf(w, "event: order\ndata: %s\n\n", data)
+	}
+
+	err = rc.Flush()
+	if err != nil {
+		app.logError(r, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	messages := sub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.shutdown:
+			fmt.Fprint(w, "event: shutdown\ndata: {}\n\n")
+			rc.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: order\ndata: %s\n\n", msg.Payload)
+		}
+
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+
+}
+
+func (app *application) orderEventsHandler(w http.ResponseWriter, r *http.Request) {
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	staff, err := app.worksAt(user, order.RestaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.placedOrder(user, order) && !staff {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	initial := orderEvent{
+		OrderID:      order.ID,
+		RestaurantID: order.RestaurantID,
+		Status:       order.Status,
+		UpdatedAt:    order.UpdatedAt,
+	}
+
+	app.streamEvents(w, r, orderChannel(order.ID), initial)
+
+}
+
+func (app *application) restaurantOrdersStreamHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	app.streamEvents(w, r, restaurantOrdersChannel(restaurantID), nil)
+
+}
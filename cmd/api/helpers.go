@@ -7,9 +7,12 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/geekilx/restaurantAPI/internal/models"
 	"github.com/geekilx/restaurantAPI/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -95,6 +98,32 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// bearerToken returns the token of the Authorization header.
+func (app *application) bearerToken(r *http.Request) (string, bool) {
+
+	headerParts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return "", false
+	}
+
+	return headerParts[1], true
+
+}
+
 func (app *application) readString(qs url.Values, key, defaultValue string) string {
 
 	val := qs.Get(key)
@@ -104,6 +133,33 @@ func (app *application) readString(qs url.Values, key, defaultValue string) stri
 	return val
 }
 
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+
+	csv := qs.Get(key)
+	if csv == "" {
+		return defaultValue
+	}
+
+	return strings.Split(csv, ",")
+
+}
+
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+
+	val := qs.Get(key)
+	if val == "" {
+		return defaultValue
+	}
+
+	boolVal, err := strconv.ParseBool(val)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return defaultValue
+	}
+	return boolVal
+
+}
+
 func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
 
 	val := qs.Get(key)
@@ -119,3 +175,102 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return intVal
 
 }
+
+// worksAt reports whether the user is on the staff of the restaurant with one
+// of the given roles, with any role when none are given.
+func (app *application) worksAt(user *models.User, restaurantID int64, roles ...string) (bool, error) {
+
+	role, err := app.models.Staff.GetRole(restaurantID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return len(roles) == 0 || slices.Contains(roles, role), nil
+
+}
+
+// inBrand reports whether the user is a member of the brand with one of the
+// given roles, with any role when none are given.
+func (app *application) inBrand(user *models.User, brandID int64, roles ...string) (bool, error) {
+
+	role, err := app.models.Brands.GetRole(brandID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return len(roles) == 0 || slices.Contains(roles, role), nil
+
+}
+
+// managesCategory reports whether the user manages the restaurant or the
+// brand the category belongs to.
+func (app *application) managesCategory(user *models.User, category *models.Category) (bool, error) {
+
+	if category.BrandID != nil {
+		return app.inBrand(user, *category.BrandID, models.BrandRoles...)
+	}
+
+	return app.worksAt(user, *category.RestaurantID, models.RestaurantManagers...)
+
+}
+
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) *time.Time {
+
+	val := qs.Get(key)
+	if val == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		v.AddError(key, "must be a RFC3339 timestamp")
+		return nil
+	}
+	return &t
+
+}
+
+// background runs fn in its own goroutine, the server waits for it before
+// shutting down and a panic in fn is only logged.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+func (app *application) readDate(qs url.Values, key string, v *validator.Validator) time.Time {
+
+	val := qs.Get(key)
+	if val == "" {
+		v.AddError(key, "must be provided")
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.DateOnly, val)
+	if err != nil {
+		v.AddError(key, "must be a date in YYYY-MM-DD format")
+		return time.Time{}
+	}
+	return t
+
+}
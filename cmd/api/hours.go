@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) showHoursHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	restaurant, ok := app.readVisibleRestaurant(w, r, restaurantID)
+	if !ok {
+		return
+	}
+
+	schedule, err := app.models.Hours.GetSchedule(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	restaurant.ApplySchedule(schedule, time.Now())
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"schedule": schedule, "is_open_now": restaurant.IsOpenNow, "next_opening_at": restaurant.NextOpeningAt}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateHoursHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		Timezone string                 `json:"timezone"`
+		Hours    []*models.OpeningHours `json:"hours"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateTimezone(v, input.Timezone); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if models.ValidateOpeningHours(v, input.Hours); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Hours.ReplaceHours(restaurantID, input.Timezone, input.Hours)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	schedule, err := app.models.Hours.GetSchedule(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"schedule": schedule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) createClosureHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		StartsOn string `json:"starts_on"`
+		EndsOn   string `json:"ends_on"`
+		Reason   string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// a single day closure only needs starts_on
+	if input.EndsOn == "" {
+		input.EndsOn = input.StartsOn
+	}
+
+	closure := models.Closure{
+		RestaurantID: restaurantID,
+		StartsOn:     input.StartsOn,
+		EndsOn:       input.EndsOn,
+		Reason:       input.Reason,
+	}
+
+	v := validator.New()
+
+	if models.ValidateClosure(v, closure); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Hours.InsertClosure(&closure)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"closure": closure}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteClosureHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	closureID, err := app.readNamedIDParam(r, "closure_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Hours.DeleteClosure(restaurantID, closureID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "closure successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
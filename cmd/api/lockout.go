@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Failed logins are counted per account and per ip in redis. Every failure of
+// an account doubles the wait before the next attempt, once MaxAttempts is
+// reached the account is locked for LockoutDuration. An ip is only blocked
+// after IPMaxAttempts failures, whatever accounts it tried.
+
+func loginFailuresKey(email string) string {
+	return "login_fail:account:" + strings.ToLower(email)
+}
+
+func loginBackoffKey(email string) string {
+	return "login_backoff:account:" + strings.ToLower(email)
+}
+
+func loginLockKey(email string) string {
+	return "login_lock:account:" + strings.ToLower(email)
+}
+
+func loginIPFailuresKey(ip string) string {
+	return "login_fail:ip:" + ip
+}
+
+// loginBackoff is the wait after the given number of failures, it starts at
+// base and doubles with every failure up to limit.
+func loginBackoff(failures int64, base, limit time.Duration) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+
+	backoff := base
+	for i := int64(1); i < failures && backoff < limit; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, limit)
+}
+
+// loginRetryAfter returns how long a login for the email from the ip has to
+// wait, zero when it may be tried right away.
+func (app *application) loginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+
+	pipe := app.redis.Pipeline()
+
+	lock := pipe.PTTL(ctx, loginLockKey(email))
+	backoff := pipe.PTTL(ctx, loginBackoffKey(email))
+	ipFailures := pipe.Get(ctx, loginIPFailuresKey(ip))
+	ipTTL := pipe.PTTL(ctx, loginIPFailuresKey(ip))
+
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+
+	wait := max(lock.Val(), backoff.Val())
+
+	if failures, _ := ipFailures.Int(); failures >= app.cfg.Login.IPMaxAttempts {
+		wait = max(wait, ipTTL.Val())
+	}
+
+	return max(wait, 0), nil
+
+}
+
+// recordLoginFailure counts a failed login and reports whether it locked the account.
+func (app *application) recordLoginFailure(ctx context.Context, email, ip string) (bool, error) {
+
+	window := app.cfg.Login.LockoutDuration
+
+	pipe := app.redis.TxPipeline()
+
+	accountFailures := pipe.Incr(ctx, loginFailuresKey(email))
+	pipe.Expire(ctx, loginFailuresKey(email), window)
+	pipe.Incr(ctx, loginIPFailuresKey(ip))
+	pipe.Expire(ctx, loginIPFailuresKey(ip), window)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	failures := accountFailures.Val()
+
+	if failures >= int64(app.cfg.Login.MaxAttempts) {
+		pipe := app.redis.TxPipeline()
+
+		pipe.Set(ctx, loginLockKey(email), 1, window)
+		pipe.Del(ctx, loginFailuresKey(email), loginBackoffKey(email))
+
+		_, err = pipe.Exec(ctx)
+		return err == nil, err
+	}
+
+	err = app.redis.Set(ctx, loginBackoffKey(email), 1, loginBackoff(failures, app.cfg.Login.BackoffBase, window)).Err()
+
+	return false, err
+
+}
+
+// clearLoginFailures forgets the failures and lock of the account, after a
+// successful login or when an admin unlocks it.
+func (app *application) clearLoginFailures(ctx context.Context, email string) error {
+	return app.redis.Del(ctx, loginFailuresKey(email), loginBackoffKey(email), loginLockKey(email)).Err()
+}
+
+// loginFailed records the failed attempt and answers with the same 401 whether
+// the account exists or not. user is nil for unknown emails.
+func (app *application) loginFailed(w http.ResponseWriter, r *http.Request, email, ip string, user *models.User) {
+
+	locked, err := app.recordLoginFailure(r.Context(), email, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if locked && user != nil {
+		app.sendLockoutEmail(user)
+	}
+
+	app.invalidUserCredintails(w, r)
+
+}
+
+// secondFactorFailed counts a wrong two-factor code like a wrong password, under
+// the keys of the account and the ip. When that locks the account the pending
+// mfa tokens are dropped as well, so the password has to be given again.
+func (app *application) secondFactorFailed(w http.ResponseWriter, r *http.Request, user *models.User, ip string) {
+
+	locked, err := app.recordLoginFailure(r.Context(), user.Email, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if locked {
+		err = app.models.Tokens.DeleteAllTokenForUser(user.ID, models.MFAPendingScope)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.sendLockoutEmail(user)
+	}
+
+	app.invalidMFACodeResponse(w, r)
+
+}
+
+func (app *application) sendLockoutEmail(user *models.User) {
+
+	data := map[string]any{
+		"firstName":      user.FirstName,
+		"lockoutMinutes": int(app.cfg.Login.LockoutDuration.Minutes()),
+	}
+
+	app.background(func() {
+		err := app.mailer.Send(user.Email, "account_locked.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+}
+
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.clearLoginFailures(r.Context(), user.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "the account was unlocked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginBackoff(t *testing.T) {
+
+	base, limit := time.Second, 15*time.Minute
+
+	assert.Equal(t, time.Duration(0), loginBackoff(0, base, limit))
+	assert.Equal(t, time.Second, loginBackoff(1, base, limit))
+	assert.Equal(t, 2*time.Second, loginBackoff(2, base, limit))
+	assert.Equal(t, 16*time.Second, loginBackoff(5, base, limit))
+	assert.Equal(t, limit, loginBackoff(30, base, limit))
+
+}
+
+func TestLoginKeysIgnoreEmailCase(t *testing.T) {
+
+	assert.Equal(t, loginFailuresKey("jane@example.com"), loginFailuresKey("Jane@Example.com"))
+	assert.Equal(t, loginLockKey("jane@example.com"), loginLockKey("JANE@EXAMPLE.COM"))
+
+}
+
+func TestLoginThrottledResponse(t *testing.T) {
+
+	app := &application{}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/users/authenticate", nil)
+
+	app.loginThrottledResponse(rw, req, 1500*time.Millisecond)
+
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "2", rw.Header().Get("Retry-After"))
+
+}
+
+// TestSecondFactorFailuresShareLoginLockout needs a redis server, it is skipped
+// unless TEST_REDIS_ADDR is set.
+func TestSecondFactorFailuresShareLoginLockout(t *testing.T) {
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+
+	app := &application{redis: redis.NewClient(&redis.Options{Addr: addr})}
+	app.cfg.Login.MaxAttempts = 5
+	app.cfg.Login.IPMaxAttempts = 20
+	app.cfg.Login.BackoffBase = time.Second
+	app.cfg.Login.LockoutDuration = time.Minute
+
+	ctx := context.Background()
+	user := &models.User{ID: 1, Email: "mfa-lockout@example.com"}
+	ip := "192.0.2.1"
+
+	t.Cleanup(func() {
+		app.redis.Del(ctx, loginFailuresKey(user.Email), loginBackoffKey(user.Email), loginLockKey(user.Email), loginIPFailuresKey(ip))
+		app.redis.Close()
+	})
+
+	for range 2 {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/tokens/mfa", nil)
+
+		app.secondFactorFailed(rw, req, user, ip)
+
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	}
+
+	// a wrong password afterwards adds to the same count
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/users/authenticate", nil)
+	app.loginFailed(rw, req, user.Email, ip, nil)
+
+	failures, err := app.redis.Get(ctx, loginFailuresKey(user.Email)).Int()
+	require.NoError(t, err)
+	assert.Equal(t, 3, failures)
+
+	ipFailures, err := app.redis.Get(ctx, loginIPFailuresKey(ip)).Int()
+	require.NoError(t, err)
+	assert.Equal(t, 3, ipFailures)
+
+	wait, err := app.loginRetryAfter(ctx, user.Email, "192.0.2.2")
+	require.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0))
+
+}
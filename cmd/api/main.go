@@ -3,19 +3,26 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/geekilx/restaurantAPI/internal/jwt"
 	"github.com/geekilx/restaurantAPI/internal/mailer"
 	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/payments"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/kelseyhightower/envconfig"
 
 	_ "github.com/lib/pq"
+
+	// restaurant time zones have to resolve in the scratch image as well
+	_ "time/tzdata"
 )
 
 const Version = "1.0.0"
@@ -41,15 +48,50 @@ type config struct {
 	Redis struct {
 		Addr string `envconfig:"REDIS_ADDR"`
 	}
+	Payments struct {
+		Provider      string `envconfig:"PAYMENT_PROVIDER" default:"fake"`
+		Currency      string `envconfig:"PAYMENT_CURRENCY" default:"EUR"`
+		WebhookSecret string `envconfig:"PAYMENT_WEBHOOK_SECRET"`
+	}
+	Reviews struct {
+		RequireOrder bool `envconfig:"REVIEWS_REQUIRE_ORDER" default:"false"`
+	}
+	Auth struct {
+		AccessTokenTTL  time.Duration `envconfig:"ACCESS_TOKEN_TTL" default:"15m"`
+		RefreshTokenTTL time.Duration `envconfig:"REFRESH_TOKEN_TTL" default:"720h"`
+	}
+	JWT struct {
+		// Keys maps every kid to its key file, e.g. "2025-01:/etc/keys/2025-01.pem,2025-02:/etc/keys/2025-02.pem"
+		Keys       map[string]string `envconfig:"JWT_KEYS"`
+		SigningKey string            `envconfig:"JWT_SIGNING_KEY"`
+		Issuer     string            `envconfig:"JWT_ISSUER" default:"restaurantAPI"`
+	}
+	Login struct {
+		MaxAttempts     int           `envconfig:"LOGIN_MAX_ATTEMPTS" default:"5"`
+		IPMaxAttempts   int           `envconfig:"LOGIN_IP_MAX_ATTEMPTS" default:"20"`
+		BackoffBase     time.Duration `envconfig:"LOGIN_BACKOFF_BASE" default:"1s"`
+		LockoutDuration time.Duration `envconfig:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+	}
+	MFA struct {
+		Issuer string `envconfig:"MFA_ISSUER" default:"restaurantAPI"`
+	}
 }
 
 type application struct {
-	cfg    config
-	logger *slog.Logger
-	models models.Models
-	mailer mailer.Mailer
-	redis  *redis.Client
-	wg     sync.WaitGroup
+	cfg      config
+	logger   *slog.Logger
+	models   models.Models
+	mailer   mailer.Mailer
+	redis    *redis.Client
+	payments payments.Provider
+	wg       sync.WaitGroup
+
+	// jwt is nil unless JWT_KEYS is set, only opaque tokens are issued then.
+	jwt *jwt.KeySet
+
+	// shutdown is closed when the server starts shutting down, long lived
+	// connections like the event streams watch it to finish in time.
+	shutdown chan struct{}
 }
 
 func main() {
@@ -95,12 +137,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	provider, err := newPaymentProvider(cfg)
+	if err != nil {
+		logger.Error("failed to create payment provider", "Error", err)
+		os.Exit(1)
+	}
+
+	var keys *jwt.KeySet
+	if len(cfg.JWT.Keys) > 0 {
+		keys, err = jwt.LoadKeySet(cfg.JWT.Issuer, cfg.JWT.SigningKey, cfg.JWT.Keys)
+		if err != nil {
+			logger.Error("failed to load jwt keys", "Error", err)
+			os.Exit(1)
+		}
+	}
+
 	app := application{
-		cfg:    cfg,
-		logger: logger,
-		models: models.NewModels(db),
-		mailer: mailer.New(cfg.Smtp.Host, cfg.Smtp.Port, cfg.Smtp.Username, cfg.Smtp.Passsword, cfg.Smtp.Sender),
-		redis:  rdb,
+		cfg:      cfg,
+		logger:   logger,
+		models:   models.NewModels(db),
+		mailer:   mailer.New(cfg.Smtp.Host, cfg.Smtp.Port, cfg.Smtp.Username, cfg.Smtp.Passsword, cfg.Smtp.Sender),
+		redis:    rdb,
+		payments: provider,
+		jwt:      keys,
 	}
 
 	err = app.serve()
@@ -141,3 +200,17 @@ func openRedis(addr string) (*redis.Client, error) {
 
 	return rdb, nil
 }
+
+func newPaymentProvider(cfg config) (payments.Provider, error) {
+	// webhooks signed with an empty key could be forged by anyone
+	if cfg.Payments.WebhookSecret == "" {
+		return nil, errors.New("PAYMENT_WEBHOOK_SECRET must be set")
+	}
+
+	switch cfg.Payments.Provider {
+	case "fake":
+		return payments.NewFake(cfg.Payments.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Payments.Provider)
+	}
+}
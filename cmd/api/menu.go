@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/geekilx/restaurantAPI/internal/models"
@@ -9,35 +10,41 @@ import (
 
 func (app *application) createMenuHandler(w http.ResponseWriter, r *http.Request) {
 
-	categoryID, err := app.readIDParam(r)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	exists := app.models.Categories.CheckIfExists(categoryID)
-	if !exists {
-		app.noCategoryIsAvailable(w, r)
+	category, ok := app.readOwnedCategory(w, r)
+	if !ok {
 		return
 	}
 
 	var input struct {
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		PriceCent   float32 `json:"price_cent"`
+		Name        string            `json:"name"`
+		Description string            `json:"description"`
+		PriceCent   float32           `json:"price_cent"`
+		DietaryTags []string          `json:"dietary_tags"`
+		Allergens   []string          `json:"allergens"`
+		Nutrition   *models.Nutrition `json:"nutrition"`
 	}
 
-	err = app.readJSON(w, r, &input)
+	err := app.readJSON(w, r, &input)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
 	menu := models.Menu{
-		CategoryID:  categoryID,
+		CategoryID:  category.ID,
 		Name:        input.Name,
 		Description: input.Description,
 		PriceCent:   input.PriceCent,
+		DietaryTags: input.DietaryTags,
+		Allergens:   input.Allergens,
+		Nutrition:   input.Nutrition,
+	}
+
+	v := validator.New()
+
+	if models.ValidateMenu(v, menu); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
 	}
 
 	err = app.models.Menu.Insert(&menu)
@@ -55,7 +62,9 @@ func (app *application) createMenuHandler(w http.ResponseWriter, r *http.Request
 func (app *application) menuListHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		name string
+		name             string
+		tags             []string
+		excludeAllergens []string
 		models.Filters
 	}
 
@@ -64,17 +73,22 @@ func (app *application) menuListHandler(w http.ResponseWriter, r *http.Request)
 	qs := r.URL.Query()
 
 	input.name = app.readString(qs, "name", "")
+	input.tags = app.readCSV(qs, "tags", []string{})
+	input.excludeAllergens = app.readCSV(qs, "exclude_allergens", []string{})
 	input.Page = app.readInt(qs, "page", 1, v)
 	input.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Sort = app.readString(qs, "sort", "id")
 	input.SortSafeList = []string{"id", "name", "description", "price_cent", "is_available", "-id", "-name", "-description", "-price_cent", "-is_available"}
 
+	models.ValidateDietaryTags(v, "tags", input.tags)
+	models.ValidateAllergens(v, "exclude_allergens", input.excludeAllergens)
+
 	if models.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	menus, metadata, err := app.models.Menu.GetAll(input.name, input.Filters)
+	menus, metadata, err := app.models.Menu.GetAll(input.name, input.tags, input.excludeAllergens, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -90,3 +104,218 @@ func (app *application) menuListHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 }
+
+func (app *application) updateMenuDietaryHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DietaryTags []string          `json:"dietary_tags"`
+		Allergens   []string          `json:"allergens"`
+		Nutrition   *models.Nutrition `json:"nutrition"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	menu := models.Menu{
+		ID:          menuID,
+		DietaryTags: input.DietaryTags,
+		Allergens:   input.Allergens,
+		Nutrition:   input.Nutrition,
+	}
+
+	v := validator.New()
+
+	if models.ValidateDietary(v, menu); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Menu.SetDietary(&menu)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"menu_id": menu.ID, "dietary_tags": menu.DietaryTags, "allergens": menu.Allergens, "nutrition": menu.Nutrition}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateMenuHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	menu, err := app.models.Menu.Get(menuID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		CategoryID  *int64   `json:"category_id"`
+		Name        *string  `json:"name"`
+		Description *string  `json:"description"`
+		PriceCent   *float32 `json:"price_cent"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		menu.Name = *input.Name
+	}
+	if input.Description != nil {
+		menu.Description = *input.Description
+	}
+	if input.PriceCent != nil {
+		menu.PriceCent = *input.PriceCent
+	}
+
+	v := validator.New()
+
+	// the item can only move to another category the seller manages
+	if input.CategoryID != nil && *input.CategoryID != menu.CategoryID {
+		category, err := app.models.Categories.Get(*input.CategoryID)
+		if err != nil && !errors.Is(err, models.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		manager := false
+		if err == nil {
+			manager, err = app.managesCategory(app.getUserContext(r), category)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		v.Check(!manager, "category_id", "no category of your restaurant found with this ID")
+		menu.CategoryID = *input.CategoryID
+	}
+
+	if models.ValidateMenu(v, *menu); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Menu.Update(menu)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"menu": menu}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateMenuAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		IsAvailable *bool `json:"is_available"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.IsAvailable == nil, "is_available", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Menu.SetAvailability(menuID, *input.IsAvailable)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"menu_id": menuID, "is_available": *input.IsAvailable}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteMenuHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Menu.Delete(menuID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "the menu item was deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) dietaryVocabularyHandler(w http.ResponseWriter, r *http.Request) {
+
+	err := app.writeJSON(w, r, http.StatusOK, jsFmt{"dietary_tags": models.DietaryTags, "allergens": models.Allergens}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/totp"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) enrolTOTPHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := app.getUserContext(r)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.MFA.StartEnrolment(user.ID, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrMFAAlreadyEnabled):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"secret": secret, "uri": totp.URI(app.cfg.MFA.Issuer, user.Email, secret)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateTOTPCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	enrolment, err := app.models.MFA.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusConflict, "start the enrolment before verifying a code")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if enrolment.Enabled {
+		app.errorResponse(w, r, http.StatusConflict, models.ErrMFAAlreadyEnabled.Error())
+		return
+	}
+
+	step, ok := totp.Validate(enrolment.Secret, input.Code, time.Now(), enrolment.LastStep)
+	if !ok {
+		v.AddError("code", "invalid code, check the clock of your device")
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	codes, err := models.GenerateRecoveryCodes()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.MFA.Enable(user.ID, step, codes)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrMFAAlreadyEnabled):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the codes are only stored hashed, this is the only time they are shown
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"recovery_codes": codes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	ok, err := app.checkSecondFactor(user.ID, input.Code, input.RecoveryCode)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.invalidMFACodeResponse(w, r)
+		return
+	}
+
+	err = app.models.MFA.Disable(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "two-factor authentication was disabled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateTOTPCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	ok, err := app.checkSecondFactor(user.ID, input.Code, "")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.invalidMFACodeResponse(w, r)
+		return
+	}
+
+	codes, err := models.GenerateRecoveryCodes()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.MFA.ReplaceRecoveryCodes(user.ID, codes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"recovery_codes": codes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// verifyMFALoginHandler is the second step of the login for users with
+// two-factor authentication, it trades the mfa token and a code for the session tokens.
+func (app *application) verifyMFALoginHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		TokenFormat  string `json:"token_format"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	models.ValidateTokenPlaintext(v, input.MFAToken)
+	app.validateTokenFormat(v, input.TokenFormat)
+	v.Check(input.Code == "" && input.RecoveryCode == "", "code", "a code or a recovery code must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID, err := app.models.Tokens.GetByToken(models.MFAPendingScope, input.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetUser(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// codes are throttled together with the passwords of the account
+	ip := clientIP(r)
+
+	wait, err := app.loginRetryAfter(r.Context(), user.Email, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		app.loginThrottledResponse(w, r, wait)
+		return
+	}
+
+	ok, err := app.checkSecondFactor(userID, input.Code, input.RecoveryCode)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.secondFactorFailed(w, r, user, ip)
+		return
+	}
+
+	err = app.clearLoginFailures(r.Context(), user.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllTokenForUser(userID, models.MFAPendingScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	pair, err := app.models.Tokens.NewPair(user.ID, app.cfg.Auth.AccessTokenTTL, app.cfg.Auth.RefreshTokenTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeTokenPair(w, r, pair, user, input.TokenFormat)
+
+}
+
+func (app *application) showMFAPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	required, err := app.models.MFA.IsRequired()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"required_for_sellers": required}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateMFAPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		RequiredForSellers *bool `json:"required_for_sellers"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.RequiredForSellers == nil, "required_for_sellers", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.MFA.SetRequired(*input.RequiredForSellers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"required_for_sellers": *input.RequiredForSellers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// checkSecondFactor verifies a code of the user's authenticator app, or a
+// recovery code when one is given. Both can be used only once.
+func (app *application) checkSecondFactor(userID int64, code, recoveryCode string) (bool, error) {
+
+	if recoveryCode != "" {
+		return app.models.MFA.UseRecoveryCode(userID, recoveryCode)
+	}
+
+	enrolment, err := app.models.MFA.Get(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	if !enrolment.Enabled {
+		return false, nil
+	}
+
+	step, ok := totp.Validate(enrolment.Secret, code, time.Now(), enrolment.LastStep)
+	if !ok {
+		return false, nil
+	}
+
+	err = app.models.MFA.UseStep(userID, step)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+
+}
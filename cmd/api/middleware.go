@@ -7,9 +7,9 @@ import (
 	"net"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/geekilx/restaurantAPI/internal/jwt"
 	"github.com/geekilx/restaurantAPI/internal/models"
 	"github.com/geekilx/restaurantAPI/internal/validator"
 )
@@ -75,20 +75,54 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		w.Header().Add("Vary", "Authorization")
 
-		authorizationHeader := r.Header.Get("Authorization")
-		if authorizationHeader == "" {
+		if r.Header.Get("Authorization") == "" {
 			r = app.setUserContext(w, r, models.AnonymousUser)
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		headerParts := strings.Split(authorizationHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		token, ok := app.bearerToken(r)
+		if !ok {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
 		}
 
-		token := headerParts[1]
+		// signed tokens carry the permissions, redis is asked whether they were
+		// revoked by a logout or a change of the user and for the user itself
+		if app.jwt != nil && jwt.LooksLikeJWT(token) {
+			claims, err := app.jwt.Verify(token, time.Now())
+			if err != nil {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+
+			revoked, err := app.signedTokenRevoked(r.Context(), claims)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if revoked {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+
+			user, err := app.cachedUser(r.Context(), claims.Subject)
+			if err != nil {
+				switch {
+				case errors.Is(err, models.ErrRecordNotFound):
+					app.invalidAuthenticationTokenResponse(w, r)
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+				return
+			}
+
+			r = app.setClaimsContext(r, claims)
+			r = app.setUserContext(w, r, user)
+			next.ServeHTTP(w, r)
+			return
+		}
 
 		v := validator.New()
 
@@ -110,6 +144,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			if err == nil {
 				err = json.Unmarshal([]byte(userJSON), &user)
 				if err == nil {
+					app.touchSession(r.Context(), token)
 					r = app.setUserContext(w, r, user)
 					next.ServeHTTP(w, r)
 					return
@@ -119,7 +154,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			// if we get to this point, it means that we could not found the token specified in the request
 			// in the redis cache, so we fallback to query the database
 		} else {
-			userID, err = app.models.Tokens.GetByToken(token)
+			userID, err = app.models.Tokens.GetByToken(models.AuthenticationScope, token)
 			if err != nil {
 				switch {
 				case errors.Is(err, models.ErrRecordNotFound):
@@ -141,9 +176,10 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		// create redis cache for user
 		if userBytes, err := json.Marshal(user); err == nil {
-			app.redis.Set(r.Context(), "user:"+strconv.FormatInt(user.ID, 10), userBytes, 24*time.Hour)
+			app.redis.Set(r.Context(), userKey(user.ID), userBytes, userCacheTTL)
 		}
 		// --- REDIS LOGIC END ---
+		app.touchSession(r.Context(), token)
 		r = app.setUserContext(w, r, user)
 
 		next.ServeHTTP(w, r)
@@ -180,6 +216,23 @@ func (app *application) requiredActivatedUser(next http.HandlerFunc) http.Handle
 	return app.requiredAutheicatedUser(fn)
 }
 
+// hasPermission reports whether the authenticated user of the request holds
+// the permission, from the signed token when there is one.
+func (app *application) hasPermission(r *http.Request, code string) (bool, error) {
+
+	if claims := app.getClaimsContext(r); claims != nil {
+		return models.Permissions(claims.Permissions).Include(code), nil
+	}
+
+	permissions, err := app.userPermissions(r.Context(), app.getUserContext(r).ID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(code), nil
+
+}
+
 func (app *application) requirePermissions(code string, next http.HandlerFunc) http.HandlerFunc {
 
 	fn := func(w http.ResponseWriter, r *http.Request) {
@@ -190,20 +243,126 @@ func (app *application) requirePermissions(code string, next http.HandlerFunc) h
 			return
 		}
 
-		permissions, err := app.models.Permissions.GetForAllUser(user.ID)
+		permitted, err := app.hasPermission(r, code)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 
-		if !permissions.Include(code) {
+		if !permitted {
 			app.notPermittedResponse(w, r)
 			return
 		}
 
+		// signed tokens of such users don't carry the permission in the first place
+		if code == "restaurant:write" && app.getClaimsContext(r) == nil {
+			missing, err := app.models.MFA.MissingRequired(user.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if missing {
+				app.mfaRequiredResponse(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requiredActivatedUser(fn)
+
+}
+
+// requireStaffAccess guards the routes of restaurant and brand staff, whether
+// the user may act on a restaurant or brand is decided by its membership in the
+// route or the handler. Sellers still have to enrol in two-factor
+// authentication first when the policy asks for it.
+func (app *application) requireStaffAccess(next http.HandlerFunc) http.HandlerFunc {
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+
+		user := app.getUserContext(r)
+
+		// looked up without the signed token, which leaves restaurant:write out
+		// for sellers that haven't enrolled
+		permissions, err := app.userPermissions(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if permissions.Include("restaurant:write") {
+			missing, err := app.models.MFA.MissingRequired(user.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if missing {
+				app.mfaRequiredResponse(w, r)
+				return
+			}
+		}
+
 		next.ServeHTTP(w, r)
 	}
 
 	return app.requiredActivatedUser(fn)
 
 }
+
+// requireRestaurantStaff makes sure the authenticated user works at the
+// restaurant in the :id path parameter with one of the roles. It has to run
+// after requireStaffAccess.
+func (app *application) requireRestaurantStaff(roles []string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		restaurantID, err := app.readIDParam(r)
+		if err != nil {
+			app.noRestaurantFound(w, r)
+			return
+		}
+
+		ok, err := app.worksAt(app.getUserContext(r), restaurantID, roles...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			app.notRestaurantOwnerResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireBrandMember makes sure the authenticated user is a member of the
+// brand in the :id path parameter with one of the roles. It has to run after
+// requireStaffAccess.
+func (app *application) requireBrandMember(roles []string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		brandID, err := app.readIDParam(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		ok, err := app.inBrand(app.getUserContext(r), brandID, roles...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			app.notBrandManagerResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) listOptionGroupsHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	category, err := app.models.Menu.GetCategory(menuID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.categoryVisible(w, r, category) {
+		return
+	}
+
+	groups, err := app.models.OptionGroups.GetAllForMenu(menuID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if groups == nil {
+		groups = []*models.OptionGroup{}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"option_groups": groups}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) createOptionGroupHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name       string `json:"name"`
+		Selection  string `json:"selection"`
+		MinChoices int    `json:"min_choices"`
+		MaxChoices *int   `json:"max_choices"`
+		Required   bool   `json:"required"`
+		Options    []struct {
+			Name           string `json:"name"`
+			PriceDeltaCent int64  `json:"price_delta_cent"`
+		} `json:"options"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	group := models.OptionGroup{
+		MenuID:     menuID,
+		Name:       input.Name,
+		Selection:  input.Selection,
+		MinChoices: input.MinChoices,
+		MaxChoices: 1,
+		Required:   input.Required,
+	}
+
+	if group.Selection == "" {
+		group.Selection = models.SelectionSingle
+	}
+	if input.MaxChoices != nil {
+		group.MaxChoices = *input.MaxChoices
+	}
+	if group.Required && group.MinChoices == 0 {
+		group.MinChoices = 1
+	}
+
+	for _, option := range input.Options {
+		group.Options = append(group.Options, &models.Option{Name: option.Name, PriceDeltaCent: option.PriceDeltaCent})
+	}
+
+	v := validator.New()
+
+	if models.ValidateOptionGroup(v, group); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.OptionGroups.Insert(&group)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"option_group": group}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteOptionGroupHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	groupID, err := app.readNamedIDParam(r, "group_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.OptionGroups.Delete(menuID, groupID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "option group successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateOptionHandler(w http.ResponseWriter, r *http.Request) {
+
+	menuID, ok := app.readOwnedMenu(w, r)
+	if !ok {
+		return
+	}
+
+	optionID, err := app.readNamedIDParam(r, "option_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	option, err := app.models.OptionGroups.GetOption(menuID, optionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name           *string `json:"name"`
+		PriceDeltaCent *int64  `json:"price_delta_cent"`
+		IsAvailable    *bool   `json:"is_available"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		option.Name = *input.Name
+	}
+	if input.PriceDeltaCent != nil {
+		option.PriceDeltaCent = *input.PriceDeltaCent
+	}
+	if input.IsAvailable != nil {
+		option.IsAvailable = *input.IsAvailable
+	}
+
+	v := validator.New()
+
+	if models.ValidateOption(v, "option", *option); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.OptionGroups.UpdateOption(option)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"option": option}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// readOwnedMenu reads the menu id of the route and checks that the menu item
+// belongs to a restaurant or a brand the seller manages.
+func (app *application) readOwnedMenu(w http.ResponseWriter, r *http.Request) (int64, bool) {
+
+	menuID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+
+	category, err := app.models.Menu.GetCategory(menuID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return 0, false
+	}
+
+	manager, err := app.managesCategory(app.getUserContext(r), category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return 0, false
+	}
+
+	if !manager {
+		app.notRestaurantOwnerResponse(w, r)
+		return 0, false
+	}
+
+	return menuID, true
+
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) listUserOrdersHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := app.getUserContext(r)
+
+	orders, err := app.models.Orders.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"orders": orders}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) showOrderHandler(w http.ResponseWriter, r *http.Request) {
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	staff, err := app.worksAt(user, order.RestaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the order is visible to the customer who placed it and to the staff of the restaurant
+	if !app.placedOrder(user, order) && !staff {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(!models.IsValidOrderStatus(input.Status), "status", "you have to provide valid status (accepted,preparing,ready,completed,cancelled)")
+	v.Check(input.Status == models.OrderStatusPlaced || input.Status == models.OrderStatusPendingPayment, "status", "orders are placed by the checkout once the payment is authorized")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	// any staff moves orders along, only owners and managers cancel them
+	var roles []string
+	if input.Status == models.OrderStatusCancelled {
+		roles = models.RestaurantManagers
+	}
+
+	staff, err := app.worksAt(user, order.RestaurantID, roles...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !staff {
+		app.notRestaurantOwnerResponse(w, r)
+		return
+	}
+
+	// the money is taken when the restaurant accepts the order
+	if input.Status == models.OrderStatusAccepted && models.CanTransition(order.Status, input.Status) {
+		err = app.capturePayment(order.ID)
+		if err != nil {
+			app.logError(r, err)
+			app.paymentCaptureFailedResponse(w, r)
+			return
+		}
+	}
+
+	if input.Status != models.OrderStatusCancelled {
+		app.transitionOrder(w, r, order, input.Status)
+		return
+	}
+
+	// the order is cancelled first so that a refund never goes out for an
+	// order that stays open, a failed refund can be retried on its own
+	if !app.changeOrderStatus(w, r, order, input.Status) {
+		return
+	}
+
+	app.publishOrder(order)
+
+	// a payment that was only authorized is released
+	err = app.voidPayment(order.ID)
+	if err != nil {
+		app.logError(r, err)
+		app.paymentVoidFailedResponse(w, r)
+		return
+	}
+
+	// cancelling a paid order gives the customer back whatever wasn't refunded yet
+	items := models.RemainingRefundItems(order)
+	if models.ValidateRefundItems(v, order, items); v.Valid() {
+		refund, err := app.refundOrder(order, items, "order cancelled by the restaurant", user.ID)
+		switch {
+		case err == nil:
+			order.TotalCent -= refund.AmountCent
+		case !errors.Is(err, models.ErrPaymentNotCaptured):
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusBadGateway, "the order was cancelled but could not be refunded, refund it again with POST /v1/orders/:id/refunds")
+			return
+		}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) cancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+	if !app.placedOrder(user, order) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// once the restaurant accepted the order only the seller can cancel it
+	if order.Status != models.OrderStatusPlaced {
+		app.invalidOrderTransitionResponse(w, r, errors.New("the order was already accepted by the restaurant and can't be cancelled"))
+		return
+	}
+
+	if !app.changeOrderStatus(w, r, order, models.OrderStatusCancelled) {
+		return
+	}
+
+	app.publishOrder(order)
+
+	// nothing was captured before the restaurant accepted, the hold is released
+	err := app.voidPayment(order.ID)
+	if err != nil {
+		app.logError(r, err)
+		app.paymentVoidFailedResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) transitionOrder(w http.ResponseWriter, r *http.Request, order *models.Order, status string) {
+
+	if !app.changeOrderStatus(w, r, order, status) {
+		return
+	}
+
+	app.publishOrder(order)
+
+	err := app.writeJSON(w, r, http.StatusOK, jsFmt{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// changeOrderStatus stores the new status of the order, it answers the request
+// itself and returns false when the order can't move there.
+func (app *application) changeOrderStatus(w http.ResponseWriter, r *http.Request, order *models.Order, status string) bool {
+
+	err := app.models.Orders.UpdateStatus(order, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidTransition):
+			app.invalidOrderTransitionResponse(w, r, err)
+		case errors.Is(err, models.ErrConflictEdit):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+
+	return true
+
+}
+
+func (app *application) readOrder(w http.ResponseWriter, r *http.Request) (*models.Order, bool) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	order, err := app.models.Orders.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return order, true
+
+}
+
+func (app *application) placedOrder(user *models.User, order *models.Order) bool {
+	return order.UserID != nil && *order.UserID == user.ID
+}
+
+func (app *application) restaurantOrdersHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input models.OrderFilters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+	input.From = app.readTime(qs, "from", v)
+	input.To = app.readTime(qs, "to", v)
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Sort = app.readString(qs, "sort", "-created_at")
+	input.SortSafeList = []string{"id", "created_at", "updated_at", "status", "total_cent", "-id", "-created_at", "-updated_at", "-status", "-total_cent"}
+
+	v.Check(input.Status != "" && !models.IsValidOrderStatus(input.Status), "status", "invalid order status")
+	v.Check(input.From != nil && input.To != nil && !input.From.Before(*input.To), "from", "from must be before to")
+	if models.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	orders, metadata, err := app.models.Orders.GetAllForRestaurant(restaurantID, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"orders": orders, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) kitchenViewHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	orders, err := app.models.Orders.GetKitchenQueue(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"orders": orders}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) bulkOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		From     string  `json:"from"`
+		To       string  `json:"to"`
+		OrderIDs []int64 `json:"order_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(!models.IsValidOrderStatus(input.From), "from", "invalid order status")
+	v.Check(!models.IsValidOrderStatus(input.To), "to", "invalid order status")
+	v.Check(input.To == models.OrderStatusPlaced || input.To == models.OrderStatusPendingPayment, "to", "orders are placed by the checkout once the payment is authorized")
+	v.Check(input.To == models.OrderStatusCancelled, "to", "orders have to be cancelled one at a time so that each one is refunded")
+	v.Check(len(input.OrderIDs) > 500, "order_ids", "must not contain more than 500 orders")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = models.ValidateTransition(input.From, input.To)
+	if err != nil {
+		app.invalidOrderTransitionResponse(w, r, err)
+		return
+	}
+
+	ids := input.OrderIDs
+	captureFailed := []int64{}
+
+	// like a single order, the money is taken before the orders are accepted and
+	// those that can't be captured stay where they are
+	if input.To == models.OrderStatusAccepted {
+		matching, err := app.models.Orders.GetIDsWithStatus(restaurantID, input.From, input.OrderIDs)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		ids = []int64{}
+		for _, id := range matching {
+			err = app.capturePayment(id)
+			if err != nil {
+				app.logger.Error("failed to capture payment", "order_id", id, "Error", err)
+				captureFailed = append(captureFailed, id)
+				continue
+			}
+
+			ids = append(ids, id)
+		}
+	}
+
+	updated := []int64{}
+
+	// no ids means every order in the from status, which is not what is left
+	// when every capture failed
+	if len(ids) > 0 || input.To != models.OrderStatusAccepted {
+		updated, err = app.models.Orders.BulkUpdateStatus(restaurantID, input.From, input.To, ids)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrInvalidTransition):
+				app.invalidOrderTransitionResponse(w, r, err)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	now := time.Now()
+	for _, id := range updated {
+		app.publishOrderEvent(orderEvent{OrderID: id, RestaurantID: restaurantID, Status: input.To, UpdatedAt: now})
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"updated": updated, "status": input.To, "capture_failed": captureFailed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
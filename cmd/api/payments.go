@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/payments"
+)
+
+// authorizePayment asks the payment provider to hold the order total and
+// records the attempt, whatever its outcome, in the payments table.
+func (app *application) authorizePayment(order *models.Order, paymentMethod string) (*models.Payment, error) {
+
+	payment := &models.Payment{
+		OrderID:    order.ID,
+		Provider:   app.payments.Name(),
+		AmountCent: order.TotalCent,
+		Status:     models.PaymentStatusPending,
+	}
+
+	err := app.models.Payments.Insert(payment)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, authErr := app.payments.Authorize(ctx, payments.AuthorizeRequest{
+		AmountCent:     order.TotalCent,
+		Currency:       app.cfg.Payments.Currency,
+		PaymentMethod:  paymentMethod,
+		OrderReference: strconv.FormatInt(order.ID, 10),
+	})
+	if authErr != nil {
+		msg := authErr.Error()
+		payment.Status = models.PaymentStatusFailed
+		payment.Error = &msg
+	} else {
+		payment.Status = models.PaymentStatusAuthorized
+		payment.ProviderRef = &result.Reference
+	}
+
+	err = app.models.Payments.Update(payment)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, authErr
+
+}
+
+// capturePayment takes the authorized amount of the order. Orders whose payment
+// was already captured are left alone so the call can safely be repeated.
+func (app *application) capturePayment(orderID int64) error {
+
+	payment, err := app.models.Payments.GetSuccessfulForOrder(orderID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status == models.PaymentStatusCaptured {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = app.payments.Capture(ctx, *payment.ProviderRef, payment.AmountCent)
+	if err != nil {
+		return err
+	}
+
+	payment.Status = models.PaymentStatusCaptured
+	payment.CapturedCent = payment.AmountCent
+
+	return app.models.Payments.Update(payment)
+
+}
+
+// voidPayment releases the authorization of a cancelled order so it can never be
+// captured. Orders without a payment or with a captured one are left alone,
+// those are refunded instead.
+func (app *application) voidPayment(orderID int64) error {
+
+	payment, err := app.models.Payments.GetSuccessfulForOrder(orderID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if payment.Status != models.PaymentStatusAuthorized {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = app.payments.Void(ctx, *payment.ProviderRef)
+	if err != nil {
+		return err
+	}
+
+	payment.Status = models.PaymentStatusVoided
+
+	return app.models.Payments.Update(payment)
+
+}
+
+func (app *application) paymentWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event, err := app.payments.VerifyWebhook(payload, r.Header)
+	if err != nil {
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid webhook signature")
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	payment, err := app.models.Payments.GetByProviderRef(app.payments.Name(), event.Reference)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			// acknowledge events for payments we don't know, otherwise the provider keeps retrying
+			app.logger.Info("webhook for unknown payment", "reference", event.Reference, "type", event.Type)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// only an authorized payment is captured or fails, retried and late events
+	// for payments that moved on are acknowledged and ignored
+	if payment.Status != models.PaymentStatusAuthorized {
+		app.logger.Info("webhook for settled payment", "reference", event.Reference, "type", event.Type, "status", payment.Status)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch event.Type {
+	case payments.EventCaptured:
+		if event.AmountCent != payment.AmountCent {
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, "the captured amount doesn't match the payment")
+			return
+		}
+		payment.Status = models.PaymentStatusCaptured
+		payment.CapturedCent = event.AmountCent
+	case payments.EventFailed:
+		payment.Status = models.PaymentStatusFailed
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = app.models.Payments.Update(payment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (app *application) orderPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	staff, err := app.worksAt(user, order.RestaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.placedOrder(user, order) && !staff {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	attempts, err := app.models.Payments.GetAllForOrder(order.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"payments": attempts}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
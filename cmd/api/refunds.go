@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+// refundOrder gives the amount of the refund items back through the payment
+// provider. The refund is reserved in the database first and released again if
+// the provider refuses it.
+func (app *application) refundOrder(order *models.Order, items []*models.RefundItem, reason string, userID int64) (*models.Refund, error) {
+
+	payment, err := app.models.Payments.GetSuccessfulForOrder(order.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			return nil, models.ErrPaymentNotCaptured
+		}
+		return nil, err
+	}
+
+	if payment.Status != models.PaymentStatusCaptured {
+		return nil, models.ErrPaymentNotCaptured
+	}
+
+	refund := &models.Refund{
+		OrderID:    order.ID,
+		PaymentID:  payment.ID,
+		AmountCent: models.RefundTotal(items),
+		Reason:     reason,
+		CreatedBy:  &userID,
+		Items:      items,
+	}
+
+	err = app.models.Refunds.Begin(refund)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := app.payments.Refund(ctx, *payment.ProviderRef, refund.AmountCent)
+	if err != nil {
+		failErr := app.models.Refunds.Fail(refund, err.Error())
+		if failErr != nil {
+			return nil, fmt.Errorf("refund %d failed (%v) and could not be released: %w", refund.ID, err, failErr)
+		}
+		return refund, err
+	}
+
+	err = app.models.Refunds.Complete(refund, result.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+
+}
+
+// cancelFullyRefundedOrder cancels the order once nothing of it is left to refund.
+func (app *application) cancelFullyRefundedOrder(order *models.Order) error {
+
+	refunded, err := app.models.Refunds.IsFullyRefunded(order.ID)
+	if err != nil || !refunded || !models.CanTransition(order.Status, models.OrderStatusCancelled) {
+		return err
+	}
+
+	err = app.models.Orders.UpdateStatus(order, models.OrderStatusCancelled)
+	if err != nil {
+		return err
+	}
+
+	app.publishOrder(order)
+
+	return nil
+
+}
+
+func (app *application) createRefundHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Reason string               `json:"reason"`
+		Items  []*models.RefundItem `json:"items"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	manager, err := app.worksAt(user, order.RestaurantID, models.RestaurantManagers...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !manager {
+		app.notRestaurantOwnerResponse(w, r)
+		return
+	}
+
+	// without items the whole order is refunded
+	items := input.Items
+	if len(items) == 0 {
+		items = models.RemainingRefundItems(order)
+	}
+
+	v := validator.New()
+
+	v.Check(v.Empty(input.Reason), "reason", "reason must be provided")
+	v.Check(len(input.Reason) > 500, "reason", "reason must not be more than 500 characters")
+	if models.ValidateRefundItems(v, order, items); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	refund, err := app.refundOrder(order, items, input.Reason, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrPaymentNotCaptured), errors.Is(err, models.ErrInvalidRefund):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case refund != nil:
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusBadGateway, "the payment provider refused the refund")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.cancelFullyRefundedOrder(order)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	order, err = app.models.Orders.Get(order.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"refund": refund, "order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listRefundsHandler(w http.ResponseWriter, r *http.Request) {
+
+	order, ok := app.readOrder(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	staff, err := app.worksAt(user, order.RestaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	admin, err := app.hasPermission(r, "admin:users:read")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !staff && !admin {
+		app.notRestaurantOwnerResponse(w, r)
+		return
+	}
+
+	refunds, err := app.models.Refunds.GetAllForOrder(order.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"refunds": refunds}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) createTableHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		Name  string `json:"name"`
+		Seats int    `json:"seats"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	table := models.Table{
+		RestaurantID: restaurantID,
+		Name:         input.Name,
+		Seats:        input.Seats,
+	}
+
+	v := validator.New()
+
+	if models.ValidateTable(v, table); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Tables.Insert(&table)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateTableName):
+			v.AddError("name", "this restaurant already has a table with this name")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"table": table}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listTablesHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	if _, ok := app.readVisibleRestaurant(w, r, restaurantID); !ok {
+		return
+	}
+
+	tables, err := app.models.Tables.GetAllForRestaurant(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"tables": tables}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteTableHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	tableID, err := app.readNamedIDParam(r, "table_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Tables.Delete(restaurantID, tableID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrTableHasReservations):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "table successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) availabilityHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	day := app.readDate(qs, "date", v)
+	partySize := app.readInt(qs, "party_size", 2, v)
+
+	v.Check(partySize < 1 || partySize > 50, "party_size", "party size must be between 1 and 50")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if _, ok := app.readVisibleRestaurant(w, r, restaurantID); !ok {
+		return
+	}
+
+	tables, err := app.models.Tables.GetAllForRestaurant(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	schedule, err := app.models.Hours.GetSchedule(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// a day the restaurant is closed has no slots at all
+	slots := models.ReservationSlots(day, schedule)
+
+	var booked []*models.Reservation
+	if len(slots) > 0 {
+		booked, err = app.models.Reservations.GetAllForRestaurant(restaurantID, slots[0], slots[len(slots)-1].Add(models.ReservationDuration), false)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// slots that already started can't be booked anymore
+	now := time.Now()
+	for len(slots) > 0 && slots[0].Before(now) {
+		slots = slots[1:]
+	}
+
+	available := models.AvailableSlots(slots, tables, booked, partySize)
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"date": day.Format(time.DateOnly), "party_size": partySize, "slots": available}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) createReservationHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		PartySize int       `json:"party_size"`
+		StartsAt  time.Time `json:"starts_at"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	restaurant, ok := app.readVisibleRestaurant(w, r, restaurantID)
+	if !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	reservation := &models.Reservation{
+		RestaurantID: restaurant.ID,
+		UserID:       &user.ID,
+		PartySize:    input.PartySize,
+		StartsAt:     input.StartsAt.UTC(),
+	}
+
+	schedule, err := app.models.Hours.GetSchedule(restaurant.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateReservation(v, *reservation, schedule); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reservations.Book(reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoTableAvailable):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.sendReservationConfirmation(user, restaurant, reservation)
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"reservation": reservation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listUserReservationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := app.getUserContext(r)
+
+	reservations, err := app.models.Reservations.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"reservations": reservations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateReservationHandler(w http.ResponseWriter, r *http.Request) {
+
+	reservation, ok := app.readUserReservation(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		PartySize *int       `json:"party_size"`
+		StartsAt  *time.Time `json:"starts_at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.PartySize != nil {
+		reservation.PartySize = *input.PartySize
+	}
+	if input.StartsAt != nil {
+		reservation.StartsAt = input.StartsAt.UTC()
+	}
+
+	schedule, err := app.models.Hours.GetSchedule(reservation.RestaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(reservation.Status != models.ReservationStatusBooked, "status", "only booked reservations can be modified")
+	if models.ValidateReservation(v, *reservation, schedule); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reservations.Modify(reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoTableAvailable):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	restaurant, err := app.models.Restaurants.Get(reservation.RestaurantID)
+	if err != nil {
+		app.logError(r, err)
+	} else {
+		app.sendReservationConfirmation(app.getUserContext(r), restaurant, reservation)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"reservation": reservation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) cancelReservationHandler(w http.ResponseWriter, r *http.Request) {
+
+	reservation, ok := app.readUserReservation(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Reservations.Cancel(reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusConflict, "the reservation was already cancelled")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"reservation": reservation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) restaurantReservationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	var day time.Time
+	if qs.Get("date") != "" {
+		day = app.readDate(qs, "date", v)
+	}
+	withCancelled := qs.Get("include_cancelled") == "true"
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	schedule, err := app.models.Hours.GetSchedule(restaurantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the day runs from midnight to midnight where the restaurant is
+	loc := schedule.Location()
+	if day.IsZero() {
+		day = time.Now().In(loc)
+	}
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+	reservations, err := app.models.Reservations.GetAllForRestaurant(restaurantID, from, from.AddDate(0, 0, 1), withCancelled)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"date": from.Format(time.DateOnly), "reservations": reservations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) readUserReservation(w http.ResponseWriter, r *http.Request) (*models.Reservation, bool) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	reservation, err := app.models.Reservations.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	user := app.getUserContext(r)
+	if reservation.UserID == nil || *reservation.UserID != user.ID {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return reservation, true
+
+}
+
+func (app *application) sendReservationConfirmation(user *models.User, restaurant *models.Restaurant, reservation *models.Reservation) {
+
+	data := map[string]any{
+		"reservationID":  reservation.ID,
+		"restaurantName": restaurant.Name,
+		"startsAt":       reservation.StartsAt.In(restaurant.Location()).Format("Monday, 02 Jan 2006 15:04 MST"),
+		"partySize":      reservation.PartySize,
+	}
+
+	app.background(func() {
+		err := app.mailer.Send(user.Email, "reservation.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/geekilx/restaurantAPI/internal/models"
 	"github.com/geekilx/restaurantAPI/internal/validator"
@@ -17,6 +18,7 @@ func (app *application) restaurantCreateHandler(w http.ResponseWriter, r *http.R
 		FullAddress string `json:"full_address"`
 		Cuisine     string `json:"cuisine"`
 		Status      string `json:"status"`
+		Timezone    string `json:"timezone"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -38,6 +40,11 @@ func (app *application) restaurantCreateHandler(w http.ResponseWriter, r *http.R
 		FullAddress: input.FullAddress,
 		Cuisine:     input.Cuisine,
 		Status:      strings.ToLower(input.Status),
+		Timezone:    input.Timezone,
+	}
+
+	if restaraunt.Timezone == "" {
+		restaraunt.Timezone = "UTC"
 	}
 
 	v := validator.New()
@@ -47,27 +54,24 @@ func (app *application) restaurantCreateHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	restaurantID, err := app.models.Restaurants.Insert(&restaraunt)
+	// the seller opens the staff of the restaurant as its first owner
+	_, err = app.models.Restaurants.Insert(&restaraunt, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, models.ErrDuplicateRestaurantName):
 			v.AddError("restaurant name", "the restaurant name was already taken")
 			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, models.ErrUserHasRestaurant):
+			app.userHasRestaurant(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	user.RestaurantID = &restaurantID
-	err = app.models.Users.Update(user)
+	err = app.forgetUser(r.Context(), user.ID)
 	if err != nil {
-		switch {
-		case errors.Is(err, models.ErrRecordNotFound):
-			app.noUserFound(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
@@ -81,7 +85,8 @@ func (app *application) restaurantCreateHandler(w http.ResponseWriter, r *http.R
 func (app *application) restaurantsListHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		name string
+		name    string
+		openNow bool
 		models.Filters
 	}
 
@@ -90,12 +95,18 @@ func (app *application) restaurantsListHandler(w http.ResponseWriter, r *http.Re
 	qs := r.URL.Query()
 
 	input.name = app.readString(qs, "name", "")
+	input.openNow = app.readBool(qs, "open_now", false, v)
 	input.Page = app.readInt(qs, "page", 1, v)
 	input.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Sort = app.readString(qs, "sort", "id")
-	input.SortSafeList = []string{"id", "name", "country", "full_address", "cuisine", "status", "-id", "-name", "-country", "-full_address", "-cuisine", "-status"}
+	input.SortSafeList = []string{"id", "name", "country", "full_address", "cuisine", "status", "rating", "review_count", "-id", "-name", "-country", "-full_address", "-cuisine", "-status", "-rating", "-review_count"}
 
-	restaraunts, metadata, err := app.models.Restaurants.GetAll(input.name, input.Filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	restaraunts, metadata, err := app.models.Restaurants.GetAll(input.name, input.openNow, models.RestaurantApproved, input.Filters)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -107,6 +118,12 @@ func (app *application) restaurantsListHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	err = app.applySchedules(restaraunts)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"restaurants": restaraunts, "metadata": metadata}, nil)
 
 }
@@ -125,6 +142,7 @@ func (app *application) restaurantUpdateHandler(w http.ResponseWriter, r *http.R
 		FullAddress string `json:"full_address"`
 		Cuisine     string `json:"cuisine"`
 		Status      string `json:"status"`
+		Timezone    string `json:"timezone"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -159,6 +177,9 @@ func (app *application) restaurantUpdateHandler(w http.ResponseWriter, r *http.R
 	if input.Status != "" {
 		restaurant.Status = input.Status
 	}
+	if input.Timezone != "" {
+		restaurant.Timezone = input.Timezone
+	}
 
 	v := validator.New()
 
@@ -231,9 +252,14 @@ func (app *application) showRestaurantHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	exists := app.models.Restaurants.CheckIfRestaurantExists(restID)
-	if !exists {
-		app.noRestaurantFound(w, r)
+	restaurant, ok := app.readVisibleRestaurant(w, r, restID)
+	if !ok {
+		return
+	}
+
+	err = app.applySchedules([]*models.Restaurant{restaurant})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
@@ -247,9 +273,72 @@ func (app *application) showRestaurantHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"menus": menus}, nil)
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"restaurant": restaurant, "menus": menus}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 
 }
+
+// readVisibleRestaurant reads the restaurant for the public endpoints, the
+// restaurants waiting for review or rejected are only shown to their staff.
+func (app *application) readVisibleRestaurant(w http.ResponseWriter, r *http.Request, id int64) (*models.Restaurant, bool) {
+
+	restaurant, err := app.models.Restaurants.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if restaurant.ReviewStatus == models.RestaurantApproved {
+		return restaurant, true
+	}
+
+	user := app.getUserContext(r)
+
+	staff := false
+	if !models.IsAnonymous(user) {
+		staff, err = app.worksAt(user, restaurant.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil, false
+		}
+	}
+
+	if !staff {
+		app.noRestaurantFound(w, r)
+		return nil, false
+	}
+
+	return restaurant, true
+
+}
+
+// applySchedules fills is_open_now and next_opening_at of the restaurants.
+func (app *application) applySchedules(restaurants []*models.Restaurant) error {
+
+	ids := make([]int64, 0, len(restaurants))
+	for _, restaurant := range restaurants {
+		ids = append(ids, restaurant.ID)
+	}
+
+	schedules, err := app.models.Hours.GetSchedules(ids)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, restaurant := range restaurants {
+		if schedule, ok := schedules[restaurant.ID]; ok {
+			restaurant.ApplySchedule(schedule, now)
+		}
+	}
+
+	return nil
+
+}
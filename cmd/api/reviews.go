@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		MenuID *int64 `json:"menu_id"`
+		Rating int    `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, ok := app.readVisibleRestaurant(w, r, restaurantID); !ok {
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	review := &models.Review{
+		UserID:       user.ID,
+		AuthorName:   user.FirstName,
+		RestaurantID: restaurantID,
+		MenuID:       input.MenuID,
+		Rating:       input.Rating,
+		Body:         input.Body,
+	}
+
+	staff, err := app.worksAt(user, restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	// the staff can't rate their own restaurant
+	v.Check(staff, "restaurant", "you can't review your own restaurant")
+
+	if input.MenuID != nil {
+		offered, err := app.models.Menu.OfferedAt(*input.MenuID, restaurantID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		v.Check(!offered, "menu_id", "this menu item doesn't belong to the restaurant")
+	}
+
+	if models.ValidateReview(v, *review); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if app.cfg.Reviews.RequireOrder {
+		ordered, err := app.models.Reviews.HasCompletedOrder(user.ID, restaurantID, input.MenuID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ordered {
+			app.errorResponse(w, r, http.StatusForbidden, "you can only review after a completed order")
+			return
+		}
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateReview):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		menuID int
+		models.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.menuID = app.readInt(qs, "menu_id", 0, v)
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Sort = app.readString(qs, "sort", "-created_at")
+	input.SortSafeList = []string{"created_at", "rating", "-created_at", "-rating"}
+
+	if models.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if _, ok := app.readVisibleRestaurant(w, r, restaurantID); !ok {
+		return
+	}
+
+	var menuID *int64
+	if input.menuID > 0 {
+		id := int64(input.menuID)
+		menuID = &id
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForRestaurant(restaurantID, menuID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	summary, err := app.models.Reviews.Summary(restaurantID, menuID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"reviews": reviews, "summary": summary, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	if review.UserID != app.getUserContext(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Rating *int    `json:"rating"`
+		Body   *string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Rating != nil {
+		review.Rating = *input.Rating
+	}
+	if input.Body != nil {
+		review.Body = *input.Body
+	}
+
+	v := validator.New()
+
+	if models.ValidateReview(v, *review); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrConflictEdit):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	if review.UserID != app.getUserContext(r).ID {
+		moderator, err := app.hasPermission(r, "admin:reviews:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !moderator {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	err := app.models.Reviews.Delete(review.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "review successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) replyReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	manager, err := app.worksAt(app.getUserContext(r), review.RestaurantID, models.RestaurantManagers...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !manager {
+		app.notRestaurantOwnerResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reply string `json:"reply"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateReply(v, input.Reply); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reviews.SetReply(review, input.Reply)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) moderateReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Hidden bool   `json:"hidden"`
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Hidden && v.Empty(input.Reason), "reason", "a reason must be provided when hiding a review")
+	v.Check(len(input.Reason) > 500, "reason", "reason must not be more than 500 characters")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if !input.Hidden {
+		input.Reason = ""
+	}
+
+	err = app.models.Reviews.SetHidden(review, input.Hidden, input.Reason)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) readReview(w http.ResponseWriter, r *http.Request) (*models.Review, bool) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	review, err := app.models.Reviews.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return review, true
+
+}
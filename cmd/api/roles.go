@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	role := &models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: input.Permissions,
+	}
+
+	if role.Permissions == nil {
+		role.Permissions = models.Permissions{}
+	}
+
+	v := validator.New()
+
+	if models.ValidateRole(v, *role, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Roles.Insert(role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateRoleName):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	role, ok := app.readRole(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Description *string  `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Description != nil {
+		role.Description = *input.Description
+	}
+	if input.Permissions != nil {
+		role.Permissions = input.Permissions
+	}
+
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateRole(v, *role, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Roles.Update(role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrConflictEdit):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.forgetRolePermissions(w, r, role.ID) {
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	role, ok := app.readRole(w, r)
+	if !ok {
+		return
+	}
+
+	// new users get their permissions from the built-in roles
+	if role.IsBuiltIn() {
+		app.errorResponse(w, r, http.StatusConflict, "built-in roles can't be deleted, change their permissions instead")
+		return
+	}
+
+	// the holders have to be looked up before the role is gone
+	if !app.forgetRolePermissions(w, r, role.ID) {
+		return
+	}
+
+	err := app.models.Roles.Delete(role.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "role successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) assignRoleHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRole(w, r, app.models.Roles.AddForUser)
+}
+
+func (app *application) unassignRoleHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRole(w, r, app.models.Roles.RemoveForUser)
+}
+
+// changeUserRole applies change with the role named in the request to the user
+// in the :id path parameter.
+func (app *application) changeUserRole(w http.ResponseWriter, r *http.Request, change func(int64, string) error) {
+
+	user, ok := app.readAdminUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(v.Empty(input.Role), "role", "role must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = change(user.ID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("role", "unknown role "+input.Role)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.forgetPermissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	roles, err := app.models.Roles.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions, err := app.userPermissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"roles": roles, "permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// forgetRolePermissions drops the cached permissions of every holder of the role.
+func (app *application) forgetRolePermissions(w http.ResponseWriter, r *http.Request, roleID int64) bool {
+
+	userIDs, err := app.models.Roles.GetUserIDs(roleID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	err = app.forgetPermissions(r.Context(), userIDs...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	return true
+
+}
+
+func (app *application) readRole(w http.ResponseWriter, r *http.Request) (*models.Role, bool) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return role, true
+
+}
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"expvar"
 	"net/http"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 
+	"github.com/geekilx/restaurantAPI/internal/models"
 	"github.com/julienschmidt/httprouter"
 )
 
 func (app *application) route() http.Handler {
 	router := httprouter.New()
 
+	// the staff taking reservations at the door
+	frontOfHouse := []string{models.StaffOwner, models.StaffManager, models.StaffCashier}
+
 	// changing the default not found response in httprouter
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.notFoundResponse(w, r)
@@ -29,19 +34,123 @@ func (app *application) route() http.Handler {
 	router.HandlerFunc(http.MethodPatch, "/v1/users/:id", app.requirePermissions("restaurant:read", app.updateUserHandler))
 	router.HandlerFunc(http.MethodPatch, "/v1/resetpassword/:id", app.requirePermissions("restaurant:read", app.resetUserPasswordHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/users/:id", app.requirePermissions("restaurant:read", app.deleteUserHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/restaurants", app.requirePermissions("restaurant:write", app.restaurantCreateHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/restaurants", app.requirePermissions("restaurant:create", app.requireStaffAccess(app.restaurantCreateHandler)))
 	router.HandlerFunc(http.MethodGet, "/v1/restaurants", app.restaurantsListHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/restaurants/:id", app.showRestaurantHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/restaurant/:id", app.requirePermissions("restaurant:write", app.restaurantUpdateHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/restaurant/:id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.restaurantUpdateHandler)))
 	router.HandlerFunc(http.MethodPost, "/v1/users/activate", app.userActivateHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/users/authenticate", app.authenticateUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/mfa", app.verifyMFALoginHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/mfa/totp", app.requiredActivatedUser(app.enrolTOTPHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/mfa/totp/verify", app.requiredActivatedUser(app.confirmTOTPHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/mfa/totp", app.requiredActivatedUser(app.disableTOTPHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/mfa/recovery-codes", app.requiredActivatedUser(app.regenerateRecoveryCodesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/mfa-policy", app.requirePermissions("admin:users:read", app.showMFAPolicyHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/mfa-policy", app.requirePermissions("admin:users:write", app.updateMFAPolicyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users", app.requirePermissions("admin:users:read", app.adminListUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id", app.requirePermissions("admin:users:read", app.adminShowUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/users/:id/status", app.requirePermissions("admin:users:write", app.adminUpdateUserStatusHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/users/:id/role", app.requirePermissions("admin:users:write", app.adminUpdateUserRoleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/logout", app.requirePermissions("admin:users:write", app.adminLogoutUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/lockout", app.requirePermissions("admin:users:write", app.unlockUserHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/permissions", app.requirePermissions("admin:users:read", app.adminListPermissionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/permissions", app.requirePermissions("admin:permissions:write", app.adminGrantPermissionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/permissions", app.requirePermissions("admin:permissions:write", app.adminRevokePermissionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/roles", app.requirePermissions("admin:permissions:write", app.assignRoleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/roles", app.requirePermissions("admin:permissions:write", app.unassignRoleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/metrics", app.requirePermissions("admin:users:read", expvar.Handler().ServeHTTP))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/restaurants", app.requirePermissions("admin:users:read", app.adminListRestaurantsHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/restaurants/:id/review", app.requirePermissions("admin:users:write", app.adminReviewRestaurantHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/roles", app.requirePermissions("admin:users:read", app.listRolesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/roles", app.requirePermissions("admin:permissions:write", app.createRoleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/roles/:id", app.requirePermissions("admin:permissions:write", app.updateRoleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/roles/:id", app.requirePermissions("admin:permissions:write", app.deleteRoleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tokens/authentication", app.requiredAutheicatedUser(app.listSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/tokens/authentication", app.requiredAutheicatedUser(app.logoutHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/tokens/authentication/all", app.requiredAutheicatedUser(app.logoutEverywhereHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/email", app.confirmEmailChangeHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/seller", app.createUserHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/category", app.allCategoryHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/category", app.requirePermissions("restaurant:write", app.createCategoryHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/category", app.requireStaffAccess(app.createCategoryHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/categories", app.showSpecificRestaurantCategory)
-	router.HandlerFunc(http.MethodPost, "/v1/category/:id/menu", app.requirePermissions("restaurant:write", app.createMenuHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/restaurant/:id/categories/order", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.reorderRestaurantCategoriesHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/category/:id/menu", app.requireStaffAccess(app.createMenuHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/category/:id/menu/order", app.requireStaffAccess(app.reorderMenuHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/menus", app.menuListHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/category/:id", app.allMenuForCategoryHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/category/:id", app.requireStaffAccess(app.updateCategoryHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/category/:id", app.requireStaffAccess(app.deleteCategoryHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/menus/:id", app.requireStaffAccess(app.updateMenuHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/menus/:id", app.requireStaffAccess(app.deleteMenuHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/menus/:id/availability", app.requireStaffAccess(app.updateMenuAvailabilityHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/dietary-tags", app.dietaryVocabularyHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/menus/:id/dietary", app.requireStaffAccess(app.updateMenuDietaryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/menus/:id/option-groups", app.listOptionGroupsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/menus/:id/option-groups", app.requireStaffAccess(app.createOptionGroupHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/menus/:id/option-groups/:group_id", app.requireStaffAccess(app.deleteOptionGroupHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/menus/:id/options/:option_id", app.requireStaffAccess(app.updateOptionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/cart", app.requirePermissions("restaurant:read", app.showCartHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/cart", app.requirePermissions("restaurant:read", app.clearCartHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/cart/items", app.requirePermissions("restaurant:read", app.addCartItemHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/cart/items/:id", app.requirePermissions("restaurant:read", app.removeCartItemHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/cart/checkout", app.requirePermissions("restaurant:read", app.checkoutHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/orders", app.requirePermissions("restaurant:read", app.listUserOrdersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/orders/:id", app.requirePermissions("restaurant:read", app.showOrderHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/orders/:id/events", app.requirePermissions("restaurant:read", app.orderEventsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/orders/:id/payments", app.requirePermissions("restaurant:read", app.orderPaymentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/payments/webhook", app.paymentWebhookHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/orders/:id/refunds", app.requirePermissions("restaurant:read", app.listRefundsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/orders/:id/refunds", app.requireStaffAccess(app.createRefundHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/orders/:id/cancel", app.requirePermissions("restaurant:read", app.cancelOrderHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/orders/:id/status", app.requireStaffAccess(app.updateOrderStatusHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/orders", app.requireStaffAccess(app.requireRestaurantStaff(models.StaffRoles, app.restaurantOrdersHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/restaurant/:id/orders", app.requireStaffAccess(app.requireRestaurantStaff(models.StaffRoles, app.bulkOrderStatusHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/orders/stream", app.requireStaffAccess(app.requireRestaurantStaff(models.StaffRoles, app.restaurantOrdersStreamHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/kitchen", app.requireStaffAccess(app.requireRestaurantStaff(models.StaffRoles, app.kitchenViewHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/tables", app.listTablesHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/restaurant/:id/tables", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.createTableHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/restaurant/:id/tables/:table_id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.deleteTableHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/reservations", app.requireStaffAccess(app.requireRestaurantStaff(frontOfHouse, app.restaurantReservationsHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/staff", app.requireStaffAccess(app.requireRestaurantStaff(models.StaffRoles, app.listStaffHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/restaurant/:id/staff/:user_id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.updateStaffRoleHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/restaurant/:id/staff/:user_id", app.requireStaffAccess(app.requireRestaurantStaff(models.StaffRoles, app.removeStaffHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurant/:id/invitations", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.listInvitationsHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/restaurant/:id/invitations", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.createInvitationHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/restaurant/:id/invitations/:invitation_id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.revokeInvitationHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/invitations/accept", app.requiredActivatedUser(app.acceptInvitationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/brands", app.requirePermissions("restaurant:write", app.createBrandHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/brands/:id", app.showBrandHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/brands/:id", app.requireStaffAccess(app.requireBrandMember(models.BrandRoles, app.updateBrandHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/brands/:id/restaurants", app.requireStaffAccess(app.requireBrandMember([]string{models.StaffOwner}, app.addBranchHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/brands/:id/restaurants/:restaurant_id", app.requireStaffAccess(app.requireBrandMember([]string{models.StaffOwner}, app.removeBranchHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/brands/:id/members", app.requireStaffAccess(app.requireBrandMember(models.BrandRoles, app.listBrandMembersHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/brands/:id/members/:user_id", app.requireStaffAccess(app.requireBrandMember([]string{models.StaffOwner}, app.updateBrandMemberHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/brands/:id/members/:user_id", app.requireStaffAccess(app.requireBrandMember(models.BrandRoles, app.removeBrandMemberHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/brands/:id/invitations", app.requireStaffAccess(app.requireBrandMember(models.BrandRoles, app.listBrandInvitationsHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/brands/:id/invitations", app.requireStaffAccess(app.requireBrandMember([]string{models.StaffOwner}, app.createBrandInvitationHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/brands/:id/invitations/:invitation_id", app.requireStaffAccess(app.requireBrandMember([]string{models.StaffOwner}, app.revokeBrandInvitationHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/brands/:id/categories", app.requireStaffAccess(app.requireBrandMember(models.BrandRoles, app.createBrandCategoryHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/brands/:id/categories/order", app.requireStaffAccess(app.requireBrandMember(models.BrandRoles, app.reorderBrandCategoriesHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/restaurant/:id/menu/:menu_id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.setMenuOverrideHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/restaurant/:id/menu/:menu_id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.deleteMenuOverrideHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurants/:id/hours", app.showHoursHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/restaurants/:id/reviews", app.listReviewsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/restaurants/:id/reviews", app.requirePermissions("restaurant:read", app.createReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/reviews/:id", app.requirePermissions("restaurant:read", app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/reviews/:id", app.requirePermissions("restaurant:read", app.deleteReviewHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/reviews/:id/reply", app.requireStaffAccess(app.replyReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/reviews/:id/visibility", app.requirePermissions("admin:reviews:write", app.moderateReviewHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/restaurant/:id/hours", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.updateHoursHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/restaurant/:id/closures", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.createClosureHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/restaurant/:id/closures/:closure_id", app.requireStaffAccess(app.requireRestaurantStaff(models.RestaurantManagers, app.deleteClosureHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/restaurants/:id/availability", app.availabilityHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/restaurants/:id/reservations", app.requirePermissions("restaurant:read", app.createReservationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/reservations", app.requirePermissions("restaurant:read", app.listUserReservationsHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/reservations/:id", app.requirePermissions("restaurant:read", app.updateReservationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/reservations/:id", app.requirePermissions("restaurant:read", app.cancelReservationHandler))
 
 	return app.panicRecover(app.rateLimit(app.authenticate(router)))
 
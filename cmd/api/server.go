@@ -22,6 +22,11 @@ func (app *application) serve() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
+	app.shutdown = make(chan struct{})
+	srv.RegisterOnShutdown(func() {
+		close(app.shutdown)
+	})
+
 	shutdownError := make(chan error)
 
 	go func() {
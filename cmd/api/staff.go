@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+// invitationTTL is how long an invitation to the staff of a restaurant can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+func (app *application) listStaffHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	members, err := app.models.Staff.GetAll(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"staff": members}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	invitation := &models.Invitation{
+		RestaurantID: &restaurantID,
+		Email:        strings.TrimSpace(input.Email),
+		Role:         input.Role,
+		InvitedBy:    user.ID,
+	}
+
+	v := validator.New()
+
+	if models.ValidateInvitation(v, *invitation); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	role, ok := app.staffRole(w, r, restaurantID)
+	if !ok {
+		return
+	}
+
+	if !models.CanManageStaff(role, invitation.Role) {
+		app.errorResponse(w, r, http.StatusForbidden, "only owners can invite owners and managers")
+		return
+	}
+
+	restaurant, err := app.models.Restaurants.Get(restaurantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRestaurantNotFound):
+			app.noRestaurantFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	token, err := models.GenerateToken(invitationTTL, user.ID, models.InvitationScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Staff.Invite(invitation, token)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		err := app.mailer.Send(invitation.Email, "staff_invitation.tmpl", map[string]any{
+			"inviterName":     user.FirstName,
+			"restaurantName":  restaurant.Name,
+			"role":            invitation.Role,
+			"invitationToken": token.PlainToken,
+		})
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) listInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	invitations, err := app.models.Staff.GetInvitations(restaurantID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"invitations": invitations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) revokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return
+	}
+
+	invitationID, err := app.readNamedIDParam(r, "invitation_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Staff.RevokeInvitation(restaurantID, invitationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "invitation successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.getUserContext(r)
+
+	invitation, err := app.models.Staff.AcceptInvitation(input.Token, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired invitation token, or the invitation was sent to another address")
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the staff role grants the permissions the staff routes require
+	err = app.models.Roles.AddForUser(user.ID, models.StaffRole)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.forgetPermissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// brand members work at every branch of the brand
+	if invitation.BrandID != nil {
+		members, err := app.models.Brands.GetMembers(*invitation.BrandID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, r, http.StatusOK, jsFmt{"members": members}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	member, err := app.models.Staff.Get(*invitation.RestaurantID, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"member": member}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) updateStaffRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	member, role, ok := app.readStaffMember(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateStaffRole(v, input.Role); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if !models.CanManageStaff(role, member.Role) || !models.CanManageStaff(role, input.Role) {
+		app.errorResponse(w, r, http.StatusForbidden, "only owners can change owners and managers")
+		return
+	}
+
+	err = app.models.Staff.UpdateRole(member.RestaurantID, member.UserID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	member.Role = input.Role
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"member": member}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) removeStaffHandler(w http.ResponseWriter, r *http.Request) {
+
+	member, role, ok := app.readStaffMember(w, r)
+	if !ok {
+		return
+	}
+
+	// everyone can leave, others are removed by the managers
+	leaving := member.UserID == app.getUserContext(r).ID
+
+	if !leaving && !models.CanManageStaff(role, member.Role) {
+		app.errorResponse(w, r, http.StatusForbidden, "only owners can remove owners and managers")
+		return
+	}
+
+	err := app.models.Staff.Remove(member.RestaurantID, member.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.dropStaffRole(r.Context(), member.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "member successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// dropStaffRole takes the staff role away from the user once the user is
+// neither on the staff of a restaurant nor a member of a brand anymore.
+func (app *application) dropStaffRole(ctx context.Context, userID int64) error {
+
+	stillStaff, err := app.models.Staff.IsMemberAnywhere(userID)
+	if err != nil || stillStaff {
+		return err
+	}
+
+	err = app.models.Roles.RemoveForUser(userID, models.StaffRole)
+	if err != nil {
+		return err
+	}
+
+	return app.forgetPermissions(ctx, userID)
+
+}
+
+// staffRole returns the role of the authenticated user at the restaurant, the
+// route makes sure there is one.
+func (app *application) staffRole(w http.ResponseWriter, r *http.Request, restaurantID int64) (string, bool) {
+
+	role, err := app.models.Staff.GetRole(restaurantID, app.getUserContext(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notRestaurantOwnerResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return "", false
+	}
+
+	return role, true
+
+}
+
+// readStaffMember returns the member in the :user_id path parameter and the
+// role of the authenticated user at the restaurant in :id.
+func (app *application) readStaffMember(w http.ResponseWriter, r *http.Request) (*models.Member, string, bool) {
+
+	restaurantID, err := app.readIDParam(r)
+	if err != nil {
+		app.noRestaurantFound(w, r)
+		return nil, "", false
+	}
+
+	userID, err := app.readNamedIDParam(r, "user_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, "", false
+	}
+
+	role, ok := app.staffRole(w, r, restaurantID)
+	if !ok {
+		return nil, "", false
+	}
+
+	member, err := app.models.Staff.Get(restaurantID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, "", false
+	}
+
+	return member, role, true
+
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/jwt"
+	"github.com/geekilx/restaurantAPI/internal/models"
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(!validator.CheckEmail(input.Email, validator.EmailRX), "email", "you should provide a valid email address")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// the response is the same whether the email is known or not, so the
+	// endpoint can't be used to find out who has an account
+	message := "if an account exists for this email, you will receive password reset instructions"
+
+	user, err := app.models.Users.GetUserByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.writeAcceptedMessage(w, r, message)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !user.IsActive {
+		app.writeAcceptedMessage(w, r, message)
+		return
+	}
+
+	// only the latest reset token stays valid
+	err = app.models.Tokens.DeleteAllTokenForUser(user.ID, models.PasswordResetScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(45*time.Minute, user.ID, models.PasswordResetScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := map[string]any{
+		"passwordResetToken": token.PlainToken,
+	}
+
+	app.background(func() {
+		err := app.mailer.Send(user.Email, "password_reset.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	app.writeAcceptedMessage(w, r, message)
+
+}
+
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	models.ValidatePassword(v, input.Password)
+	if models.ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID, err := app.models.Tokens.GetByToken(models.PasswordResetScope, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Users.ChangePassword(userID, input.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.noUserFound(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the reset token is used up and every session opened with the old password ends
+	err = app.models.Tokens.DeleteAllTokenForUser(userID, models.PasswordResetScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.revokeSessions(r.Context(), userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+		TokenFormat  string `json:"token_format"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	app.validateTokenFormat(v, input.TokenFormat)
+	if models.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	pair, userID, err := app.models.Tokens.Rotate(input.RefreshToken, app.cfg.Auth.AccessTokenTTL, app.cfg.Auth.RefreshTokenTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTokenReused):
+			// the family is gone from the database, its access tokens must not
+			// survive in the cache or as signed tokens either
+			err = app.purgeUserCache(r.Context(), userID)
+			if err == nil {
+				err = app.revokeSignedTokens(r.Context(), userID)
+			}
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			app.invalidAuthenticationTokenResponse(w, r)
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetUser(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeTokenPair(w, r, pair, user, input.TokenFormat)
+
+}
+
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	token, _ := app.bearerToken(r)
+	user := app.getUserContext(r)
+
+	sessions, err := app.models.Tokens.GetSessions(user.ID, token)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"sessions": sessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+
+	token, _ := app.bearerToken(r)
+	user := app.getUserContext(r)
+
+	// the refresh token of the session goes too, so the login can't be renewed
+	var err error
+	if claims := app.getClaimsContext(r); claims != nil {
+		err = app.models.Tokens.DeleteFamily(claims.SessionID)
+		if err == nil {
+			err = app.revokeSignedSession(r.Context(), claims.SessionID)
+		}
+	} else {
+		err = app.models.Tokens.RevokeFamily(token)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// earlier access tokens of the family may still be cached, the other
+	// sessions simply fall back to the database
+	err = app.purgeUserCache(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "you have been logged out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) logoutEverywhereHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := app.getUserContext(r)
+
+	err := app.revokeSessions(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "all your sessions have been logged out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// revokeSessions logs the user out everywhere, signed tokens included.
+func (app *application) revokeSessions(ctx context.Context, userID int64) error {
+
+	for _, scope := range []string{models.AuthenticationScope, models.RefreshScope} {
+		err := app.models.Tokens.DeleteAllTokenForUser(userID, scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := app.purgeUserCache(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return app.revokeSignedTokens(ctx, userID)
+
+}
+
+func (app *application) validateTokenFormat(v *validator.Validator, format string) {
+	v.Check(!validator.PermittedValue(format, "", "opaque", "jwt"), "token_format", "token format must be opaque or jwt")
+	v.Check(format == "jwt" && app.jwt == nil, "token_format", "jwt tokens are not enabled")
+}
+
+// writeTokenPair answers a login or a refresh. With the jwt format the access
+// token is handed out as a signed token, the stored opaque one just expires.
+func (app *application) writeTokenPair(w http.ResponseWriter, r *http.Request, pair *models.TokenPair, user *models.User, format string) {
+
+	accessToken := pair.Access.PlainToken
+
+	if format == "jwt" {
+		permissions, err := app.userPermissions(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if permissions.Include("restaurant:write") {
+			missing, err := app.models.MFA.MissingRequired(user.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if missing {
+				permissions = slices.DeleteFunc(permissions, func(code string) bool { return code == "restaurant:write" })
+			}
+		}
+
+		accessToken, err = app.jwt.Sign(jwt.Claims{
+			Subject:      user.ID,
+			SessionID:    pair.Family,
+			IssuedAt:     time.Now().Unix(),
+			Expiry:       pair.Access.Expiry.Unix(),
+			Role:         user.Role,
+			Permissions:  permissions,
+			Activated:    user.IsActive,
+			RestaurantID: user.RestaurantID,
+		})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		app.cacheToken(r.Context(), pair.Access, user)
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, jsFmt{
+		"token":          accessToken,
+		"expiry":         pair.Access.Expiry.Format(time.RFC822),
+		"refresh_token":  pair.Refresh.PlainToken,
+		"refresh_expiry": pair.Refresh.Expiry.Format(time.RFC822),
+	}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+func (app *application) writeAcceptedMessage(w http.ResponseWriter, r *http.Request, message string) {
+
+	err := app.writeJSON(w, r, http.StatusAccepted, jsFmt{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
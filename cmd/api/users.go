@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/geekilx/restaurantAPI/internal/models"
@@ -81,25 +79,13 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		}
 	}()
 
-	readRole := "restaurant:read"
-
-	if r.URL.Path == "/v1/seller" {
-		writeRole := "restaurant:write"
-		err = app.models.Permissions.AddForUser(user.ID, writeRole)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-	}
-
-	err = app.models.Permissions.AddForUser(user.ID, readRole)
+	// the permissions come from the role named like the user's role
+	err = app.models.Roles.AddForUser(user.ID, user.Role)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	println("added permission for user: " + user.FirstName)
-
 	err = app.writeJSON(w, r, http.StatusCreated, jsFmt{"user": user, "message": "Please check your email in order to activate your account"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -115,6 +101,11 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if app.getUserContext(r).ID != id {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
 	user, err := app.models.Users.GetUser(id)
 	if err != nil {
 		switch {
@@ -144,8 +135,29 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 	if input.LastName != "" {
 		user.LastName = input.LastName
 	}
-	if input.Email != "" {
-		user.Email = input.Email
+
+	// a new email only replaces the current one once it's confirmed
+	emailChanged := input.Email != "" && input.Email != user.Email
+
+	if emailChanged {
+		v := validator.New()
+
+		v.Check(!validator.CheckEmail(input.Email, validator.EmailRX), "email", "you should provide a valid email address")
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+
+		_, err = app.models.Users.GetUserByEmail(input.Email)
+		switch {
+		case err == nil:
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v)
+			return
+		case !errors.Is(err, models.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 	}
 
 	err = app.models.Users.Update(user)
@@ -160,9 +172,21 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// delete user id in redis in order to update the cache
-	app.redis.Del(r.Context(), "user:"+strconv.FormatInt(user.ID, 10))
+	app.forgetUser(r.Context(), user.ID)
 
-	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "user successfully updated"}, nil)
+	message := "user successfully updated"
+
+	if emailChanged {
+		err = app.requestEmailChange(user, input.Email)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		message = "user successfully updated, confirm the new email address with the token we sent to it"
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": message}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -193,6 +217,16 @@ func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// the cached and signed tokens would otherwise still authenticate the deleted user
+	err = app.purgeUserCache(r.Context(), id)
+	if err == nil {
+		err = app.revokeSignedTokens(r.Context(), id)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"messeage": "user successfully deleted."}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -246,7 +280,7 @@ func (app *application) userActivateHandler(w http.ResponseWriter, r *http.Reque
 
 	v := validator.New()
 
-	id, err := app.models.Tokens.GetByToken(input.Token)
+	id, err := app.models.Tokens.GetByToken(models.ActivationScope, input.Token)
 	if err != nil {
 		switch {
 		case errors.Is(err, models.ErrRecordNotFound):
@@ -290,7 +324,7 @@ func (app *application) userActivateHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	// delete user id in redis in order to update the cache
-	app.redis.Del(r.Context(), "user:"+strconv.FormatInt(user.ID, 10))
+	app.forgetUser(r.Context(), user.ID)
 
 	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "user successfully updated"}, nil)
 	if err != nil {
@@ -301,8 +335,9 @@ func (app *application) userActivateHandler(w http.ResponseWriter, r *http.Reque
 
 func (app *application) authenticateUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+		TokenFormat string `json:"token_format"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -311,11 +346,31 @@ func (app *application) authenticateUserHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	v := validator.New()
+
+	if app.validateTokenFormat(v, input.TokenFormat); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	ip := clientIP(r)
+
+	wait, err := app.loginRetryAfter(r.Context(), input.Email, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		app.loginThrottledResponse(w, r, wait)
+		return
+	}
+
 	user, err := app.models.Users.GetUserByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, models.ErrRecordNotFound):
-			app.invalidUserCredintails(w, r)
+			app.loginFailed(w, r, input.Email, ip, nil)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -323,38 +378,53 @@ func (app *application) authenticateUserHandler(w http.ResponseWriter, r *http.R
 	}
 
 	ok, err := user.Password.Matches(input.Password)
-	if err != nil || !ok {
+	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	token, err := app.models.Tokens.New(24*time.Hour, user.ID, models.AuthenticationScope)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	if !ok {
+		app.loginFailed(w, r, input.Email, ip, user)
 		return
 	}
 
-	userBytes, err := json.Marshal(user)
+	mfaEnabled, err := app.models.MFA.IsEnabled(user.ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.redis.Set(r.Context(), "token:"+token.PlainToken, user.ID, 24*time.Hour).Err()
-	if err != nil {
-		app.logger.Error("failed to cache user id", "Error", err)
+	// the password alone only buys a few minutes to give the second factor, the
+	// failures are kept until the code is right too or a known password would
+	// reset the count of guessed codes
+	if mfaEnabled {
+		token, err := app.models.Tokens.New(5*time.Minute, user.ID, models.MFAPendingScope)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, r, http.StatusOK, jsFmt{"mfa_required": true, "mfa_token": token.PlainToken, "expiry": token.Expiry.Format(time.RFC822)}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	err = app.redis.Set(r.Context(), "user:"+strconv.FormatInt(user.ID, 10), userBytes, 24*time.Hour).Err()
+	err = app.clearLoginFailures(r.Context(), input.Email)
 	if err != nil {
-		app.logger.Error("failed to cache user", "Error", err)
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"token": token.PlainToken, "expiry": token.Expiry.Format(time.RFC822)}, nil)
+	pair, err := app.models.Tokens.NewPair(user.ID, app.cfg.Auth.AccessTokenTTL, app.cfg.Auth.RefreshTokenTTL)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
 
+	app.writeTokenPair(w, r, pair, user, input.TokenFormat)
+
 }
 
 func (app *application) resetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
@@ -432,3 +502,105 @@ func (app *application) resetUserPasswordHandler(w http.ResponseWriter, r *http.
 	}
 
 }
+
+// requestEmailChange stores the pending address, mails the confirmation token
+// to it and lets the current address know about the change.
+func (app *application) requestEmailChange(user *models.User, newEmail string) error {
+
+	err := app.models.Users.RequestEmailChange(user.ID, newEmail)
+	if err != nil {
+		return err
+	}
+
+	// only the token of the latest request stays valid
+	err = app.models.Tokens.DeleteAllTokenForUser(user.ID, models.EmailChangeScope)
+	if err != nil {
+		return err
+	}
+
+	token, err := app.models.Tokens.New(24*time.Hour, user.ID, models.EmailChangeScope)
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		err := app.mailer.Send(newEmail, "email_change.tmpl", map[string]any{
+			"firstName":        user.FirstName,
+			"emailChangeToken": token.PlainToken,
+		})
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+
+		err = app.mailer.Send(user.Email, "email_change_notice.tmpl", map[string]any{
+			"firstName": user.FirstName,
+			"newEmail":  newEmail,
+		})
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	return nil
+
+}
+
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateTokenPlaintext(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID, err := app.models.Tokens.GetByToken(models.EmailChangeScope, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	email, err := app.models.Users.ConfirmEmailChange(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, models.ErrDuplicateEmail):
+			// someone registered the address after the change was requested
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.forgetUser(r.Context(), userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, jsFmt{"message": "your email address was changed", "email": email}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
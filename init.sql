@@ -19,6 +19,13 @@ SET xmloption = content;
 SET client_min_messages = warning;
 SET row_security = off;
 
+--
+-- Name: btree_gist; Type: EXTENSION; Schema: -; Owner: -
+--
+
+CREATE EXTENSION IF NOT EXISTS btree_gist WITH SCHEMA public;
+
+
 SET default_tablespace = '';
 
 SET default_table_access_method = heap;
@@ -31,7 +38,10 @@ CREATE TABLE public.categories (
     id bigint NOT NULL,
     restaurant_id bigint,
     name character varying(50) NOT NULL,
-    created_at timestamp without time zone DEFAULT now()
+    created_at timestamp without time zone DEFAULT now(),
+    brand_id bigint,
+    "position" integer DEFAULT 0 NOT NULL,
+    CONSTRAINT check_category_owner_constraint CHECK (((restaurant_id IS NULL) <> (brand_id IS NULL)))
 );
 
 
@@ -70,7 +80,16 @@ CREATE TABLE public.menu (
     price_cent integer NOT NULL,
     is_available boolean DEFAULT true,
     created_at timestamp without time zone DEFAULT now(),
-    CONSTRAINT check_isavailable_constraint CHECK (((is_available = true) OR (is_available = false)))
+    dietary_tags text[] DEFAULT '{}'::text[] NOT NULL,
+    allergens text[] DEFAULT '{}'::text[] NOT NULL,
+    kcal integer,
+    protein_g double precision,
+    carbs_g double precision,
+    fat_g double precision,
+    "position" integer DEFAULT 0 NOT NULL,
+    CONSTRAINT check_isavailable_constraint CHECK (((is_available = true) OR (is_available = false))),
+    CONSTRAINT check_menu_dietary_tags_constraint CHECK ((dietary_tags <@ ARRAY['vegan'::text, 'vegetarian'::text, 'gluten_free'::text, 'dairy_free'::text, 'halal'::text, 'kosher'::text, 'spicy'::text])),
+    CONSTRAINT check_menu_allergens_constraint CHECK ((allergens <@ ARRAY['celery'::text, 'cereals_gluten'::text, 'crustaceans'::text, 'eggs'::text, 'fish'::text, 'lupin'::text, 'milk'::text, 'molluscs'::text, 'mustard'::text, 'nuts'::text, 'peanuts'::text, 'sesame'::text, 'soya'::text, 'sulphites'::text]))
 );
 
 
@@ -143,7 +162,13 @@ CREATE TABLE public.restaurant (
     status character varying(50) NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone DEFAULT now() NOT NULL,
-    CONSTRAINT check_status_constarint CHECK ((((status)::text = 'open'::text) OR ((status)::text = 'closed'::text)))
+    timezone text DEFAULT 'UTC'::text NOT NULL,
+    brand_id bigint,
+    review_status character varying(20) DEFAULT 'pending_review'::character varying NOT NULL,
+    review_reason text,
+    reviewed_at timestamp with time zone,
+    CONSTRAINT check_status_constarint CHECK ((((status)::text = 'open'::text) OR ((status)::text = 'closed'::text))),
+    CONSTRAINT check_review_status_constraint CHECK (((review_status)::text = ANY ((ARRAY['pending_review'::character varying, 'approved'::character varying, 'rejected'::character varying])::text[])))
 );
 
 
@@ -178,10 +203,18 @@ CREATE TABLE public.tokens (
     hash bytea NOT NULL,
     user_id bigint,
     expiry timestamp with time zone NOT NULL,
-    scope text NOT NULL
+    scope text NOT NULL,
+    id bigserial NOT NULL UNIQUE,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_used_at timestamp with time zone,
+    family text,
+    used_at timestamp with time zone
 );
 
 
+CREATE INDEX tokens_family_idx ON public.tokens USING btree (family);
+
+
 ALTER TABLE public.tokens OWNER TO ilx;
 
 --
@@ -289,6 +322,10 @@ ALTER TABLE ONLY public.categories
 ALTER TABLE ONLY public.menu
     ADD CONSTRAINT menu_pkey PRIMARY KEY (id);
 
+CREATE INDEX menu_dietary_tags_idx ON public.menu USING gin (dietary_tags);
+CREATE INDEX menu_allergens_idx ON public.menu USING gin (allergens);
+CREATE INDEX menu_category_id_position_idx ON public.menu USING btree (category_id, "position");
+
 
 --
 -- Name: permissions permissions_pkey; Type: CONSTRAINT; Schema: public; Owner: ilx
@@ -298,6 +335,14 @@ ALTER TABLE ONLY public.permissions
     ADD CONSTRAINT permissions_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: permissions permissions_code_key; Type: CONSTRAINT; Schema: public; Owner: ilx
+--
+
+ALTER TABLE ONLY public.permissions
+    ADD CONSTRAINT permissions_code_key UNIQUE (code);
+
+
 --
 -- Name: restaurant restaurant_name_key; Type: CONSTRAINT; Schema: public; Owner: ilx
 --
@@ -338,6 +383,14 @@ ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: users_permissions users_permissions_pkey; Type: CONSTRAINT; Schema: public; Owner: ilx
+--
+
+ALTER TABLE ONLY public.users_permissions
+    ADD CONSTRAINT users_permissions_pkey PRIMARY KEY (user_id, permission_id);
+
+
 --
 -- Name: categories categories_restaurant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: ilx
 --
@@ -385,10 +438,499 @@ ALTER TABLE ONLY public.users_permissions
 ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_restaurant_id_fkey FOREIGN KEY (restaurant_id) REFERENCES public.restaurant(id) ON DELETE SET NULL;
 
+--
+-- Name: cart_items; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.cart_items (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    menu_id bigint NOT NULL REFERENCES public.menu(id) ON DELETE CASCADE,
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    option_ids bigint[] DEFAULT '{}'::bigint[] NOT NULL,
+    quantity integer NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT cart_items_line_key UNIQUE (user_id, menu_id, option_ids),
+    CONSTRAINT check_cart_quantity_constraint CHECK (((quantity > 0) AND (quantity <= 100)))
+);
+
+
+ALTER TABLE public.cart_items OWNER TO ilx;
+
+--
+-- Name: orders; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.orders (
+    id bigserial PRIMARY KEY,
+    user_id bigint REFERENCES public.users(id) ON DELETE SET NULL,
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    status character varying(20) NOT NULL DEFAULT 'pending_payment'::character varying,
+    total_cent bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_order_status_constraint CHECK (((status)::text = ANY ((ARRAY['pending_payment'::character varying, 'placed'::character varying, 'accepted'::character varying, 'preparing'::character varying, 'ready'::character varying, 'completed'::character varying, 'cancelled'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.orders OWNER TO ilx;
+
+CREATE INDEX orders_restaurant_id_status_idx ON public.orders USING btree (restaurant_id, status);
+CREATE INDEX orders_user_id_idx ON public.orders USING btree (user_id);
+
+--
+-- Name: order_items; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.order_items (
+    id bigserial PRIMARY KEY,
+    order_id bigint NOT NULL REFERENCES public.orders(id) ON DELETE CASCADE,
+    menu_id bigint REFERENCES public.menu(id) ON DELETE SET NULL,
+    name character varying(100) NOT NULL,
+    options text[] DEFAULT '{}'::text[] NOT NULL,
+    quantity integer NOT NULL,
+    unit_price_cent bigint NOT NULL,
+    refunded_quantity integer DEFAULT 0 NOT NULL,
+    CONSTRAINT check_order_item_quantity_constraint CHECK ((quantity > 0)),
+    CONSTRAINT check_order_item_refunded_constraint CHECK (((refunded_quantity >= 0) AND (refunded_quantity <= quantity)))
+);
+
+
+ALTER TABLE public.order_items OWNER TO ilx;
+
+--
+-- Name: payments; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.payments (
+    id bigserial PRIMARY KEY,
+    order_id bigint NOT NULL REFERENCES public.orders(id) ON DELETE CASCADE,
+    provider character varying(50) NOT NULL,
+    provider_ref text,
+    amount_cent bigint NOT NULL,
+    captured_cent bigint DEFAULT 0 NOT NULL,
+    refunded_cent bigint DEFAULT 0 NOT NULL,
+    status character varying(20) NOT NULL,
+    error text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_payment_status_constraint CHECK (((status)::text = ANY ((ARRAY['pending'::character varying, 'authorized'::character varying, 'captured'::character varying, 'failed'::character varying, 'voided'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.payments OWNER TO ilx;
+
+CREATE INDEX payments_order_id_idx ON public.payments USING btree (order_id);
+CREATE UNIQUE INDEX payments_provider_ref_idx ON public.payments USING btree (provider, provider_ref);
+
+--
+-- Name: refunds; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.refunds (
+    id bigserial PRIMARY KEY,
+    order_id bigint NOT NULL REFERENCES public.orders(id) ON DELETE CASCADE,
+    payment_id bigint NOT NULL REFERENCES public.payments(id) ON DELETE CASCADE,
+    amount_cent bigint NOT NULL,
+    reason text NOT NULL,
+    status character varying(20) NOT NULL,
+    provider_ref text,
+    error text,
+    created_by bigint REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_refund_status_constraint CHECK (((status)::text = ANY ((ARRAY['pending'::character varying, 'succeeded'::character varying, 'failed'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.refunds OWNER TO ilx;
+
+CREATE INDEX refunds_order_id_idx ON public.refunds USING btree (order_id);
+
+--
+-- Name: refund_items; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.refund_items (
+    refund_id bigint NOT NULL REFERENCES public.refunds(id) ON DELETE CASCADE,
+    order_item_id bigint NOT NULL REFERENCES public.order_items(id) ON DELETE CASCADE,
+    quantity integer NOT NULL,
+    amount_cent bigint NOT NULL,
+    PRIMARY KEY (refund_id, order_item_id)
+);
+
+
+ALTER TABLE public.refund_items OWNER TO ilx;
+
+--
+-- Name: restaurant_tables; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.restaurant_tables (
+    id bigserial PRIMARY KEY,
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    name character varying(50) NOT NULL,
+    seats integer NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT restaurant_tables_name_key UNIQUE (restaurant_id, name),
+    CONSTRAINT check_table_seats_constraint CHECK ((seats > 0))
+);
+
+
+ALTER TABLE public.restaurant_tables OWNER TO ilx;
+
+--
+-- Name: reservations; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.reservations (
+    id bigserial PRIMARY KEY,
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    table_id bigint NOT NULL REFERENCES public.restaurant_tables(id),
+    user_id bigint REFERENCES public.users(id) ON DELETE SET NULL,
+    party_size integer NOT NULL,
+    starts_at timestamp with time zone NOT NULL,
+    ends_at timestamp with time zone NOT NULL,
+    status character varying(20) DEFAULT 'booked'::character varying NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_reservation_status_constraint CHECK ((((status)::text = 'booked'::text) OR ((status)::text = 'cancelled'::text))),
+    CONSTRAINT check_reservation_time_constraint CHECK ((starts_at < ends_at)),
+    CONSTRAINT reservations_no_overlap EXCLUDE USING gist (table_id WITH =, tstzrange(starts_at, ends_at) WITH &&) WHERE (((status)::text = 'booked'::text))
+);
+
+
+ALTER TABLE public.reservations OWNER TO ilx;
+
+CREATE INDEX reservations_restaurant_id_starts_at_idx ON public.reservations USING btree (restaurant_id, starts_at);
+CREATE INDEX reservations_user_id_idx ON public.reservations USING btree (user_id);
+
+--
+-- Name: opening_hours; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.opening_hours (
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    weekday smallint NOT NULL,
+    opens_at time without time zone NOT NULL,
+    closes_at time without time zone NOT NULL,
+    PRIMARY KEY (restaurant_id, weekday, opens_at),
+    CONSTRAINT check_weekday_constraint CHECK (((weekday >= 0) AND (weekday <= 6)))
+);
+
+
+ALTER TABLE public.opening_hours OWNER TO ilx;
+
+--
+-- Name: restaurant_closures; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.restaurant_closures (
+    id bigserial PRIMARY KEY,
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    starts_on date NOT NULL,
+    ends_on date NOT NULL,
+    reason text DEFAULT ''::text NOT NULL,
+    CONSTRAINT check_closure_dates_constraint CHECK ((starts_on <= ends_on))
+);
+
+
+ALTER TABLE public.restaurant_closures OWNER TO ilx;
+
+CREATE INDEX restaurant_closures_restaurant_id_idx ON public.restaurant_closures USING btree (restaurant_id, ends_on);
+
+--
+-- Name: option_groups; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.option_groups (
+    id bigserial PRIMARY KEY,
+    menu_id bigint NOT NULL REFERENCES public.menu(id) ON DELETE CASCADE,
+    name character varying(100) NOT NULL,
+    selection character varying(10) NOT NULL,
+    min_choices integer DEFAULT 0 NOT NULL,
+    max_choices integer DEFAULT 1 NOT NULL,
+    required boolean DEFAULT false NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_option_group_selection_constraint CHECK (((selection)::text = ANY ((ARRAY['single'::character varying, 'multi'::character varying])::text[]))),
+    CONSTRAINT check_option_group_choices_constraint CHECK (((min_choices >= 0) AND (max_choices >= 1) AND (min_choices <= max_choices)))
+);
+
+
+ALTER TABLE public.option_groups OWNER TO ilx;
+
+CREATE INDEX option_groups_menu_id_idx ON public.option_groups USING btree (menu_id);
+
+--
+-- Name: options; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.options (
+    id bigserial PRIMARY KEY,
+    group_id bigint NOT NULL REFERENCES public.option_groups(id) ON DELETE CASCADE,
+    name character varying(100) NOT NULL,
+    price_delta_cent bigint DEFAULT 0 NOT NULL,
+    is_available boolean DEFAULT true NOT NULL
+);
+
+
+ALTER TABLE public.options OWNER TO ilx;
+
+CREATE INDEX options_group_id_idx ON public.options USING btree (group_id);
+
+--
+-- Name: reviews; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.reviews (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    menu_id bigint REFERENCES public.menu(id) ON DELETE CASCADE,
+    rating smallint NOT NULL,
+    body text DEFAULT ''::text NOT NULL,
+    reply text,
+    replied_at timestamp with time zone,
+    is_hidden boolean DEFAULT false NOT NULL,
+    hidden_reason text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT check_review_rating_constraint CHECK (((rating >= 1) AND (rating <= 5)))
+);
+
+
+ALTER TABLE public.reviews OWNER TO ilx;
+
+CREATE UNIQUE INDEX reviews_user_restaurant_key ON public.reviews USING btree (user_id, restaurant_id) WHERE (menu_id IS NULL);
+CREATE UNIQUE INDEX reviews_user_menu_key ON public.reviews USING btree (user_id, menu_id) WHERE (menu_id IS NOT NULL);
+CREATE INDEX reviews_restaurant_id_idx ON public.reviews USING btree (restaurant_id, created_at);
+
+--
+-- Name: user_totp; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.user_totp (
+    user_id bigint PRIMARY KEY REFERENCES public.users(id) ON DELETE CASCADE,
+    secret text NOT NULL,
+    enabled boolean DEFAULT false NOT NULL,
+    last_step bigint DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.user_totp OWNER TO ilx;
+
+--
+-- Name: recovery_codes; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    hash bytea NOT NULL,
+    used_at timestamp with time zone
+);
+
+
+ALTER TABLE public.recovery_codes OWNER TO ilx;
+
+CREATE INDEX recovery_codes_user_id_idx ON public.recovery_codes USING btree (user_id);
+
+--
+-- Name: email_changes; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.email_changes (
+    user_id bigint PRIMARY KEY REFERENCES public.users(id) ON DELETE CASCADE,
+    new_email character varying(100) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.email_changes OWNER TO ilx;
+
+--
+-- Name: roles; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.roles (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    description text DEFAULT ''::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT roles_name_key UNIQUE (name)
+);
+
+
+ALTER TABLE public.roles OWNER TO ilx;
+
+--
+-- Name: roles_permissions; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.roles_permissions (
+    role_id bigint NOT NULL REFERENCES public.roles(id) ON DELETE CASCADE,
+    permission_id bigint NOT NULL REFERENCES public.permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+
+ALTER TABLE public.roles_permissions OWNER TO ilx;
+
+--
+-- Name: users_roles; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.users_roles (
+    user_id bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    role_id bigint NOT NULL REFERENCES public.roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+
+ALTER TABLE public.users_roles OWNER TO ilx;
+
+CREATE INDEX users_roles_role_id_idx ON public.users_roles USING btree (role_id);
+
+--
+-- Name: restaurant_members; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.restaurant_members (
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    role text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (restaurant_id, user_id),
+    CONSTRAINT restaurant_members_role_check CHECK (role = ANY (ARRAY['owner'::text, 'manager'::text, 'cashier'::text, 'kitchen'::text]))
+);
+
+
+ALTER TABLE public.restaurant_members OWNER TO ilx;
+
+CREATE INDEX restaurant_members_user_id_idx ON public.restaurant_members USING btree (user_id);
+
+--
+-- Name: restaurant_invitations; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.restaurant_invitations (
+    id bigserial PRIMARY KEY,
+    token_hash bytea NOT NULL UNIQUE REFERENCES public.tokens(hash) ON DELETE CASCADE,
+    restaurant_id bigint REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    brand_id bigint,
+    email character varying(100) NOT NULL,
+    role text NOT NULL,
+    invited_by bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT restaurant_invitations_owner_check CHECK (((restaurant_id IS NULL) <> (brand_id IS NULL))),
+    CONSTRAINT restaurant_invitations_role_check CHECK (role = ANY (ARRAY['owner'::text, 'manager'::text, 'cashier'::text, 'kitchen'::text]))
+);
+
+
+ALTER TABLE public.restaurant_invitations OWNER TO ilx;
+
+CREATE INDEX restaurant_invitations_restaurant_id_idx ON public.restaurant_invitations USING btree (restaurant_id);
+
+--
+-- Name: settings; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.settings (
+    name text PRIMARY KEY,
+    value text NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.settings OWNER TO ilx;
+
+--
+-- Name: brands; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.brands (
+    id bigserial PRIMARY KEY,
+    name character varying(50) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT brands_name_key UNIQUE (name)
+);
+
+
+ALTER TABLE public.brands OWNER TO ilx;
+
+ALTER TABLE ONLY public.restaurant
+    ADD CONSTRAINT restaurant_brand_id_fkey FOREIGN KEY (brand_id) REFERENCES public.brands(id) ON DELETE SET NULL;
+
+ALTER TABLE ONLY public.categories
+    ADD CONSTRAINT categories_brand_id_fkey FOREIGN KEY (brand_id) REFERENCES public.brands(id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.restaurant_invitations
+    ADD CONSTRAINT restaurant_invitations_brand_id_fkey FOREIGN KEY (brand_id) REFERENCES public.brands(id) ON DELETE CASCADE;
+
+CREATE INDEX restaurant_brand_id_idx ON public.restaurant USING btree (brand_id);
+
+CREATE INDEX restaurant_invitations_brand_id_idx ON public.restaurant_invitations USING btree (brand_id);
+
+--
+-- Name: brand_members; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.brand_members (
+    brand_id bigint NOT NULL REFERENCES public.brands(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    role text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (brand_id, user_id),
+    CONSTRAINT brand_members_role_check CHECK (role = ANY (ARRAY['owner'::text, 'manager'::text]))
+);
+
+
+ALTER TABLE public.brand_members OWNER TO ilx;
+
+CREATE INDEX brand_members_user_id_idx ON public.brand_members USING btree (user_id);
+
+--
+-- Name: menu_overrides; Type: TABLE; Schema: public; Owner: ilx
+--
+
+CREATE TABLE public.menu_overrides (
+    restaurant_id bigint NOT NULL REFERENCES public.restaurant(id) ON DELETE CASCADE,
+    menu_id bigint NOT NULL REFERENCES public.menu(id) ON DELETE CASCADE,
+    price_cent integer,
+    is_available boolean,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (restaurant_id, menu_id),
+    CONSTRAINT check_override_price_constraint CHECK ((price_cent >= 0))
+);
+
+
+ALTER TABLE public.menu_overrides OWNER TO ilx;
+
 INSERT INTO public.permissions (code)
 VALUES
 ('restaurant:read'),
-('restaurant:write');
+('restaurant:create'),
+('restaurant:write'),
+('admin:users:read'),
+('admin:users:write'),
+('admin:permissions:write'),
+('admin:reviews:write');
+
+INSERT INTO public.roles (name, description)
+VALUES
+('customer', 'Given to every customer on sign up'),
+('seller', 'Given to every seller on sign up'),
+('admin', 'Given to users whose role is changed to admin'),
+('staff', 'Given to users who join the staff of a restaurant');
+
+INSERT INTO public.roles_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM public.roles r, public.permissions p
+WHERE (r.name = 'customer' AND p.code = 'restaurant:read')
+OR (r.name = 'staff' AND p.code = 'restaurant:read')
+OR (r.name = 'seller' AND p.code IN ('restaurant:read', 'restaurant:create'))
+OR (r.name = 'admin' AND (p.code = 'restaurant:read' OR p.code LIKE 'admin:%'));
 
 --
 -- PostgreSQL database dump complete
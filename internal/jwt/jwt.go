@@ -0,0 +1,248 @@
+// Package jwt signs and verifies the stateless access tokens handed out next to
+// the opaque ones. Only the compact JWS form is supported, with EdDSA (Ed25519)
+// or HS256 signatures, and the key is picked by the kid header so keys can be
+// rotated without invalidating the tokens already out there.
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+const (
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+)
+
+// minSecretLength is the shortest HMAC secret accepted, shorter secrets can be
+// brute forced from a single token.
+const minSecretLength = 32
+
+var encoding = base64.RawURLEncoding
+
+type Claims struct {
+	Issuer       string   `json:"iss"`
+	Subject      int64    `json:"sub,string"`
+	SessionID    string   `json:"sid,omitempty"`
+	IssuedAt     int64    `json:"iat"`
+	Expiry       int64    `json:"exp"`
+	Role         string   `json:"role"`
+	Permissions  []string `json:"permissions"`
+	Activated    bool     `json:"activated"`
+	RestaurantID *int64   `json:"restaurant_id,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Key is one entry of the key set. Ed25519 keys loaded from a public key can
+// only verify, which is how retired keys are kept around until their tokens expire.
+type Key struct {
+	ID         string
+	Alg        string
+	secret     []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func (k *Key) canSign() bool {
+	return k.Alg == AlgHS256 || k.privateKey != nil
+}
+
+func (k *Key) sign(input []byte) []byte {
+	if k.Alg == AlgHS256 {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(k.privateKey, input)
+}
+
+func (k *Key) verify(input, signature []byte) bool {
+	if k.Alg == AlgHS256 {
+		return hmac.Equal(k.sign(input), signature)
+	}
+	return ed25519.Verify(k.publicKey, input, signature)
+}
+
+// ParseKey reads a key from its file content: a PEM "PRIVATE KEY" or
+// "PUBLIC KEY" holds an Ed25519 key, anything else is an HMAC secret.
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < minSecretLength {
+			return nil, fmt.Errorf("jwt key %q: hmac secret must be at least %d bytes", id, minSecretLength)
+		}
+		return &Key{ID: id, Alg: AlgHS256, secret: secret}, nil
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", id, err)
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("jwt key %q: only ed25519 private keys are supported", id)
+		}
+		return &Key{ID: id, Alg: AlgEdDSA, privateKey: privateKey, publicKey: privateKey.Public().(ed25519.PublicKey)}, nil
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", id, err)
+		}
+		publicKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("jwt key %q: only ed25519 public keys are supported", id)
+		}
+		return &Key{ID: id, Alg: AlgEdDSA, publicKey: publicKey}, nil
+	default:
+		return nil, fmt.Errorf("jwt key %q: unsupported pem block %q", id, block.Type)
+	}
+}
+
+// KeySet holds every key tokens are accepted from, new tokens are signed with
+// the signing key only.
+type KeySet struct {
+	Issuer  string
+	keys    map[string]*Key
+	signing *Key
+}
+
+func NewKeySet(issuer, signingKid string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{Issuer: issuer, keys: make(map[string]*Key)}
+
+	for _, key := range keys {
+		ks.keys[key.ID] = key
+	}
+
+	signing, ok := ks.keys[signingKid]
+	if !ok {
+		return nil, fmt.Errorf("jwt signing key %q is not in the key set", signingKid)
+	}
+	if !signing.canSign() {
+		return nil, fmt.Errorf("jwt signing key %q is a public key", signingKid)
+	}
+
+	ks.signing = signing
+
+	return ks, nil
+}
+
+// LoadKeySet reads the keys from files, files maps every kid to the path of its key.
+func LoadKeySet(issuer, signingKid string, files map[string]string) (*KeySet, error) {
+	var keys []*Key
+
+	for id, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", id, err)
+		}
+
+		key, err := ParseKey(id, data)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(issuer, signingKid, keys...)
+}
+
+// Sign fills in the issuer and returns the signed token.
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	claims.Issuer = ks.Issuer
+
+	headerJSON, err := json.Marshal(header{Alg: ks.signing.Alg, Typ: "JWT", Kid: ks.signing.ID})
+	if err != nil {
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+
+	return input + "." + encoding.EncodeToString(ks.signing.sign([]byte(input))), nil
+}
+
+// Verify checks the signature, issuer and expiry of the token and returns its claims.
+func (ks *KeySet) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+
+	err := decodeSegment(parts[0], &h)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := ks.keys[h.Kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	// the algorithm comes from the key, never from the token, otherwise a
+	// public key could be passed off as an hmac secret
+	if h.Alg != key.Alg {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+
+	err = decodeSegment(parts[1], &claims)
+	if err != nil || claims.Issuer != ks.Issuer || claims.Subject < 1 {
+		return nil, ErrInvalidToken
+	}
+
+	if now.Unix() >= claims.Expiry {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// LooksLikeJWT tells a JWT apart from an opaque token without verifying it.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func decodeSegment(segment string, dst any) error {
+	data, err := encoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
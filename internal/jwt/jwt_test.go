@@ -0,0 +1,134 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ed25519Keys(t *testing.T) (privatePEM, publicPEM []byte) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func TestSignVerify(t *testing.T) {
+
+	privatePEM, _ := ed25519Keys(t)
+
+	edKey, err := ParseKey("ed-1", privatePEM)
+	require.NoError(t, err)
+	assert.Equal(t, AlgEdDSA, edKey.Alg)
+
+	hmacKey, err := ParseKey("hs-1", []byte(strings.Repeat("s", 32)+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, AlgHS256, hmacKey.Alg)
+
+	now := time.Now()
+	restaurantID := int64(7)
+
+	for _, kid := range []string{"ed-1", "hs-1"} {
+		ks, err := NewKeySet("restaurantAPI", kid, edKey, hmacKey)
+		require.NoError(t, err)
+
+		token, err := ks.Sign(Claims{Subject: 42, Expiry: now.Add(time.Minute).Unix(), Role: "seller",
+			Permissions: []string{"restaurant:read"}, Activated: true, RestaurantID: &restaurantID})
+		require.NoError(t, err)
+		assert.True(t, LooksLikeJWT(token))
+
+		claims, err := ks.Verify(token, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), claims.Subject)
+		assert.Equal(t, "seller", claims.Role)
+		assert.Equal(t, []string{"restaurant:read"}, claims.Permissions)
+		assert.Equal(t, &restaurantID, claims.RestaurantID)
+
+		_, err = ks.Verify(token, now.Add(time.Minute))
+		assert.ErrorIs(t, err, ErrExpiredToken)
+
+		_, err = ks.Verify(token[:len(token)-2]+"AA", now)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+
+}
+
+func TestKeyRotation(t *testing.T) {
+
+	oldPrivate, oldPublic := ed25519Keys(t)
+	newPrivate, _ := ed25519Keys(t)
+
+	oldKey, err := ParseKey("2025-01", oldPrivate)
+	require.NoError(t, err)
+	newKey, err := ParseKey("2025-02", newPrivate)
+	require.NoError(t, err)
+
+	before, err := NewKeySet("restaurantAPI", "2025-01", oldKey)
+	require.NoError(t, err)
+
+	token, err := before.Sign(Claims{Subject: 1, Expiry: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	// the old key is kept as a public key only until its tokens expired
+	retired, err := ParseKey("2025-01", oldPublic)
+	require.NoError(t, err)
+
+	after, err := NewKeySet("restaurantAPI", "2025-02", retired, newKey)
+	require.NoError(t, err)
+
+	_, err = after.Verify(token, time.Now())
+	assert.NoError(t, err)
+
+	_, err = NewKeySet("restaurantAPI", "2025-01", retired, newKey)
+	assert.Error(t, err)
+
+	later, err := NewKeySet("restaurantAPI", "2025-02", newKey)
+	require.NoError(t, err)
+
+	_, err = later.Verify(token, time.Now())
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+}
+
+func TestVerifyRejectsForeignTokens(t *testing.T) {
+
+	secret := []byte(strings.Repeat("k", 32))
+
+	key, err := ParseKey("hs-1", secret)
+	require.NoError(t, err)
+
+	ks, err := NewKeySet("restaurantAPI", "hs-1", key)
+	require.NoError(t, err)
+
+	other, err := NewKeySet("someone-else", "hs-1", key)
+	require.NoError(t, err)
+
+	token, err := other.Sign(Claims{Subject: 1, Expiry: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	_, err = ks.Verify(token, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = ks.Verify("not-a-token", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = ParseKey("short", []byte("too-short"))
+	assert.Error(t, err)
+
+}
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+// BrandRoles are the roles of the members of a brand, they hold the same role
+// at every branch of the brand.
+var BrandRoles = RestaurantManagers
+
+// Brand is a restaurant chain. Its branches are restaurants inheriting the
+// categories and menus of the brand, see MenuModel.GetRestaurantMenus.
+type Brand struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BrandMember is a user managing every branch of a brand.
+type BrandMember struct {
+	BrandID   int64     `json:"brand_id"`
+	UserID    int64     `json:"user_id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BrandModel struct {
+	DB *sql.DB
+}
+
+// Insert creates the brand with ownerID as its first owner.
+func (m *BrandModel) Insert(brand *Brand, ownerID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO brands (name) VALUES($1) RETURNING id, created_at, updated_at`, brand.Name).Scan(&brand.ID, &brand.CreatedAt, &brand.UpdatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "brands_name_key"):
+			return ErrDuplicateBrandName
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO brand_members (brand_id, user_id, role) VALUES($1, $2, $3)`, brand.ID, ownerID, StaffOwner)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *BrandModel) Get(id int64) (*Brand, error) {
+	stmt := `SELECT id, name, created_at, updated_at FROM brands WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var brand Brand
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&brand.ID, &brand.Name, &brand.CreatedAt, &brand.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &brand, nil
+}
+
+func (m *BrandModel) Update(brand *Brand) error {
+	stmt := `UPDATE brands SET name = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, brand.Name, brand.ID).Scan(&brand.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case strings.Contains(err.Error(), "brands_name_key"):
+			return ErrDuplicateBrandName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetBranches returns the approved restaurants of the brand.
+func (m *BrandModel) GetBranches(brandID int64) ([]*Restaurant, error) {
+	stmt := `SELECT id, name, country, full_address, cuisine, status, timezone, review_status, created_at, updated_at
+	FROM restaurant WHERE brand_id = $1 AND review_status = 'approved' ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, brandID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	restaurants := []*Restaurant{}
+
+	for rows.Next() {
+		var restaurant Restaurant
+
+		err = rows.Scan(&restaurant.ID, &restaurant.Name, &restaurant.Country, &restaurant.FullAddress, &restaurant.Cuisine, &restaurant.Status, &restaurant.Timezone, &restaurant.ReviewStatus, &restaurant.CreatedAt, &restaurant.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		restaurant.BrandID = &brandID
+
+		restaurants = append(restaurants, &restaurant)
+	}
+
+	return restaurants, rows.Err()
+}
+
+// AddBranch makes the restaurant a branch of the brand, it fails with
+// ErrRestaurantHasBrand when the restaurant already belongs to a brand.
+func (m *BrandModel) AddBranch(brandID, restaurantID int64) error {
+	stmt := `UPDATE restaurant SET brand_id = $1, updated_at = NOW() WHERE id = $2 AND brand_id IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, brandID, restaurantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRestaurantHasBrand
+	}
+
+	return nil
+}
+
+// RemoveBranch detaches the restaurant from the brand together with its
+// overrides of the brand menus.
+func (m *BrandModel) RemoveBranch(brandID, restaurantID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE restaurant SET brand_id = NULL, updated_at = NOW() WHERE id = $1 AND brand_id = $2`, restaurantID, brandID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM menu_overrides WHERE restaurant_id = $1`, restaurantID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRole returns the role of the user in the brand, ErrRecordNotFound when
+// the user isn't a member.
+func (m *BrandModel) GetRole(brandID, userID int64) (string, error) {
+	stmt := `SELECT role FROM brand_members WHERE brand_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var role string
+
+	err := m.DB.QueryRowContext(ctx, stmt, brandID, userID).Scan(&role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
+func (m *BrandModel) GetMembers(brandID int64) ([]*BrandMember, error) {
+	stmt := `SELECT bm.brand_id, bm.user_id, u.first_name, u.last_name, u.email, bm.role, bm.created_at
+	FROM brand_members bm INNER JOIN users u ON u.id = bm.user_id
+	WHERE bm.brand_id = $1 ORDER BY bm.created_at, bm.user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, brandID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []*BrandMember{}
+
+	for rows.Next() {
+		var member BrandMember
+
+		err = rows.Scan(&member.BrandID, &member.UserID, &member.FirstName, &member.LastName, &member.Email, &member.Role, &member.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &member)
+	}
+
+	return members, rows.Err()
+}
+
+// UpdateMember changes the role of a member, it fails with ErrLastOwner when it
+// would leave the brand without an owner. New members join through an invitation.
+func (m *BrandModel) UpdateMember(brandID, userID int64, role string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != StaffOwner {
+		err = checkNotLastOwner(ctx, tx, "brand_members", "brand_id", brandID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE brand_members SET role = $1 WHERE brand_id = $2 AND user_id = $3`, role, brandID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember fails with ErrLastOwner when the user is the only owner left.
+func (m *BrandModel) RemoveMember(brandID, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkNotLastOwner(ctx, tx, "brand_members", "brand_id", brandID, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM brand_members WHERE brand_id = $1 AND user_id = $2`, brandID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+func ValidateBrand(v *validator.Validator, brand Brand) {
+	v.Check(v.Empty(brand.Name), "name", "name must be provided")
+	v.Check(len(brand.Name) > 50, "name", "name must not be more than 50 characters")
+}
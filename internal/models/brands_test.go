@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBrand(t *testing.T) {
+
+	v := validator.New()
+	ValidateBrand(v, Brand{Name: "Burger Palace"})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateBrand(v, Brand{})
+	assert.Contains(t, v.FieldErorrs, "name")
+
+	v = validator.New()
+	ValidateBrand(v, Brand{Name: strings.Repeat("a", 51)})
+	assert.Contains(t, v.FieldErorrs, "name")
+
+}
+
+func TestValidateMenuOverride(t *testing.T) {
+
+	price := int64(450)
+	negative := int64(-1)
+	available := false
+
+	v := validator.New()
+	ValidateMenuOverride(v, MenuOverride{PriceCent: &price})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateMenuOverride(v, MenuOverride{IsAvailable: &available})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateMenuOverride(v, MenuOverride{})
+	assert.Contains(t, v.FieldErorrs, "override")
+
+	v = validator.New()
+	ValidateMenuOverride(v, MenuOverride{PriceCent: &negative})
+	assert.Contains(t, v.FieldErorrs, "price_cent")
+
+}
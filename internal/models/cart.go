@@ -0,0 +1,277 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+// CartItem is one line of the cart. The same menu item can be in the cart
+// several times with different options, UnitPriceCent includes the price
+// deltas of the selected options.
+type CartItem struct {
+	ID            int64     `json:"id"`
+	MenuID        int64     `json:"menu_id"`
+	Name          string    `json:"name"`
+	RestaurantID  int64     `json:"restaurant_id"`
+	Quantity      int       `json:"quantity"`
+	UnitPriceCent int64     `json:"unit_price_cent"`
+	IsAvailable   bool      `json:"is_available"`
+	OptionIDs     []int64   `json:"-"`
+	Options       []*Option `json:"options,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MaxCartQuantity is the most a single cart line can hold.
+const MaxCartQuantity = 100
+
+// cartLines selects the cart lines of a user with the price and the
+// availability of the menu items at the restaurant they were added for. An
+// item of a brand the restaurant left is not available anymore.
+const cartLines = `SELECT ci.id, ci.menu_id, m.name, ci.restaurant_id, ci.quantity, COALESCE(mo.price_cent, m.price_cent),
+	COALESCE(COALESCE(mo.is_available, m.is_available) AND (c.restaurant_id = r.id OR c.brand_id = r.brand_id), false),
+	ci.option_ids, ci.created_at FROM cart_items ci
+	INNER JOIN menu m on m.id = ci.menu_id
+	INNER JOIN categories c on c.id = m.category_id
+	INNER JOIN restaurant r on r.id = ci.restaurant_id
+	LEFT JOIN menu_overrides mo on mo.menu_id = ci.menu_id AND mo.restaurant_id = ci.restaurant_id
+	WHERE ci.user_id = $1
+	ORDER BY ci.created_at ASC, ci.id ASC`
+
+type CartModel struct {
+	DB *sql.DB
+}
+
+// AddItem puts a menu item with the selected options into the user's cart, or
+// increases its quantity when the same line is already there. A cart can only
+// hold items from a single, approved restaurant. The items of a brand are ordered from
+// one of its branches, restaurantID picks it and is required for them.
+func (m *CartModel) AddItem(userID, menuID, restaurantID int64, optionIDs []int64, quantity int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `SELECT COALESCE(c.restaurant_id, r.id) FROM menu m
+	INNER JOIN categories c on c.id = m.category_id
+	LEFT JOIN restaurant r on r.brand_id = c.brand_id AND r.id = $2
+	WHERE m.id = $1 AND (c.restaurant_id = $2 OR $2 = 0 OR r.id IS NOT NULL)`
+
+	var offeredAt sql.NullInt64
+	err := m.DB.QueryRowContext(ctx, stmt, menuID, restaurantID).Scan(&offeredAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if !offeredAt.Valid {
+		return ErrBranchRequired
+	}
+	restaurantID = offeredAt.Int64
+
+	// the menus of restaurants waiting for review can't be ordered yet
+	var approved bool
+	err = m.DB.QueryRowContext(ctx, `SELECT review_status = 'approved' FROM restaurant WHERE id = $1`, restaurantID).Scan(&approved)
+	if err != nil {
+		return err
+	}
+
+	if !approved {
+		return ErrRecordNotFound
+	}
+
+	stmt = `SELECT EXISTS(SELECT FROM cart_items WHERE user_id = $1 AND restaurant_id <> $2)`
+
+	var otherRestaurant bool
+	err = m.DB.QueryRowContext(ctx, stmt, userID, restaurantID).Scan(&otherRestaurant)
+	if err != nil {
+		return err
+	}
+
+	if otherRestaurant {
+		return ErrMixedRestaurantCart
+	}
+
+	// the ids are sorted so the same selection always lands on the same line
+	optionIDs = slices.Clone(optionIDs)
+	slices.Sort(optionIDs)
+
+	// a line that would grow past MaxCartQuantity is left alone
+	stmt = `INSERT INTO cart_items (user_id, menu_id, restaurant_id, option_ids, quantity) VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id, menu_id, option_ids) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity
+	WHERE cart_items.quantity + EXCLUDED.quantity <= $6`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, menuID, restaurantID, pq.Array(optionIDs), quantity, MaxCartQuantity)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCartQuantityExceeded
+	}
+
+	return nil
+
+}
+
+func (m *CartModel) GetForUser(userID int64) ([]*CartItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, cartLines, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var items []*CartItem
+	for rows.Next() {
+		var item CartItem
+
+		err := rows.Scan(&item.ID, &item.MenuID, &item.Name, &item.RestaurantID, &item.Quantity, &item.UnitPriceCent, &item.IsAvailable, pq.Array(&item.OptionIDs), &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadCartOptions(ctx, m.DB, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+
+}
+
+func (m *CartModel) RemoveItem(userID, id int64) error {
+	stmt := `DELETE FROM cart_items WHERE user_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, userID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+
+}
+
+func (m *CartModel) Clear(userID int64) error {
+	stmt := `DELETE FROM cart_items WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID)
+	return err
+
+}
+
+// loadCartOptions fills the selected options of the cart lines and adds their
+// price deltas to the unit price. A line with an option that was removed or is
+// not available anymore is marked as unavailable.
+func loadCartOptions(ctx context.Context, q queryer, items []*CartItem) error {
+	var ids []int64
+	for _, item := range items {
+		ids = append(ids, item.OptionIDs...)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	stmt := `SELECT id, group_id, name, price_delta_cent, is_available FROM options WHERE id = ANY($1)`
+
+	rows, err := q.QueryContext(ctx, stmt, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	options := make(map[int64]*Option)
+	for rows.Next() {
+		var option Option
+
+		err := rows.Scan(&option.ID, &option.GroupID, &option.Name, &option.PriceDeltaCent, &option.IsAvailable)
+		if err != nil {
+			return err
+		}
+
+		options[option.ID] = &option
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		for _, id := range item.OptionIDs {
+			option, ok := options[id]
+			if !ok || !option.IsAvailable {
+				item.IsAvailable = false
+			}
+			if ok {
+				item.Options = append(item.Options, option)
+				item.UnitPriceCent += option.PriceDeltaCent
+			}
+		}
+
+		// discounts of the options can't make the item pay out
+		item.UnitPriceCent = max(item.UnitPriceCent, 0)
+	}
+
+	return nil
+}
+
+// OptionNames returns the names of the selected options, kept on the order item.
+func (item *CartItem) OptionNames() []string {
+	names := []string{}
+	for _, option := range item.Options {
+		names = append(names, option.Name)
+	}
+	return names
+}
+
+func CartTotal(items []*CartItem) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.UnitPriceCent * int64(item.Quantity)
+	}
+	return total
+}
+
+func ValidateCartQuantity(v *validator.Validator, quantity int) {
+	v.Check(quantity < 1, "quantity", "quantity must be at least 1")
+	v.Check(quantity > MaxCartQuantity, "quantity", "quantity must not be more than 100")
+}
@@ -3,15 +3,22 @@ package models
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
 )
 
+// Category groups the menus of a restaurant, or of a brand when BrandID is set.
+// The categories of a brand are shared by all its branches.
 type Category struct {
 	ID             int64     `json:"id"`
-	RestaurantID   int64     `json:"restaurant_id"`
+	RestaurantID   *int64    `json:"restaurant_id,omitempty"`
+	BrandID        *int64    `json:"brand_id,omitempty"`
 	Name           string    `json:"name"`
 	RestaurantName string    `json:"restaurant_name,omitempty"`
+	Position       int       `json:"position"`
 	CreatedAt      time.Time `json:"created_at"`
 }
 
@@ -19,13 +26,16 @@ type CategoryModel struct {
 	DB *sql.DB
 }
 
+// Insert puts the category after the other categories of its restaurant or brand.
 func (m *CategoryModel) Insert(category *Category) error {
-	stmt := `INSERT INTO categories (restaurant_id, name) VALUES($1, $2) RETURNING id, created_at`
+	stmt := `INSERT INTO categories (restaurant_id, brand_id, name, position)
+	SELECT $1, $2, $3, COALESCE(MAX(position), 0) + 1 FROM categories WHERE restaurant_id IS NOT DISTINCT FROM $1 AND brand_id IS NOT DISTINCT FROM $2
+	RETURNING id, position, created_at`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, stmt, category.RestaurantID, category.Name).Scan(&category.ID, &category.CreatedAt)
+	err := m.DB.QueryRowContext(ctx, stmt, category.RestaurantID, category.BrandID, category.Name).Scan(&category.ID, &category.Position, &category.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -34,14 +44,17 @@ func (m *CategoryModel) Insert(category *Category) error {
 
 }
 
-func (m *CategoryModel) CategoryExists(name string, restaurantID int64) bool {
-	stmt := `SELECT EXISTS(SELECT FROM categories where name = $1 AND restaurant_id = $2)`
+// CategoryExists reports whether the restaurant or the brand of the category
+// already has a category with its name.
+func (m *CategoryModel) CategoryExists(category Category) bool {
+	stmt := `SELECT EXISTS(SELECT FROM categories where name = $1
+	AND restaurant_id IS NOT DISTINCT FROM $2 AND brand_id IS NOT DISTINCT FROM $3)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var ok bool
-	err := m.DB.QueryRowContext(ctx, stmt, name, restaurantID).Scan(&ok)
+	err := m.DB.QueryRowContext(ctx, stmt, category.Name, category.RestaurantID, category.BrandID).Scan(&ok)
 	if err != nil || ok {
 		return true
 	}
@@ -51,8 +64,11 @@ func (m *CategoryModel) CategoryExists(name string, restaurantID int64) bool {
 }
 
 func (m *CategoryModel) GetAll(name string, f Filters) ([]*Category, Metadata, error) {
-	stmt := fmt.Sprintf(`SELECT count(*) OVER(), c.id, r.name, c.restaurant_id, c.name, c.created_at FROM categories c inner join restaurant r on r.id = c.restaurant_id
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), c.id, COALESCE(r.name, b.name), c.restaurant_id, c.brand_id, c.name, c.position, c.created_at FROM categories c
+		LEFT JOIN restaurant r on r.id = c.restaurant_id
+		LEFT JOIN brands b on b.id = c.brand_id
 		WHERE (to_tsvector('simple', c.name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (c.brand_id IS NOT NULL OR r.review_status = 'approved')
 	ORDER BY %s %s, id ASC LIMIT %d OFFSET %d`, f.sortColumn(), f.sortDirection(), f.Limit(), f.Offset())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -68,7 +84,7 @@ func (m *CategoryModel) GetAll(name string, f Filters) ([]*Category, Metadata, e
 	for rows.Next() {
 		var category Category
 
-		err := rows.Scan(&totalRecords, &category.ID, &category.RestaurantName, &category.RestaurantID, &category.Name, &category.CreatedAt)
+		err := rows.Scan(&totalRecords, &category.ID, &category.RestaurantName, &category.RestaurantID, &category.BrandID, &category.Name, &category.Position, &category.CreatedAt)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -82,8 +98,12 @@ func (m *CategoryModel) GetAll(name string, f Filters) ([]*Category, Metadata, e
 
 }
 
+// GetAllForRestaurant returns the categories the restaurant inherits from its
+// brand followed by its own ones, each in their position.
 func (m *CategoryModel) GetAllForRestaurant(id int64) ([]*Category, error) {
-	stmt := `SELECT * from categories where restaurant_id = $1`
+	stmt := `SELECT id, restaurant_id, brand_id, name, position, created_at from categories
+	WHERE restaurant_id = $1 OR brand_id = (SELECT brand_id FROM restaurant WHERE id = $1)
+	ORDER BY brand_id IS NULL, position, id`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -96,7 +116,7 @@ func (m *CategoryModel) GetAllForRestaurant(id int64) ([]*Category, error) {
 	var categories []*Category
 	for rows.Next() {
 		var category Category
-		err := rows.Scan(&category.ID, &category.RestaurantID, &category.Name, &category.CreatedAt)
+		err := rows.Scan(&category.ID, &category.RestaurantID, &category.BrandID, &category.Name, &category.Position, &category.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -107,17 +127,86 @@ func (m *CategoryModel) GetAllForRestaurant(id int64) ([]*Category, error) {
 	return categories, nil
 }
 
-func (m *CategoryModel) CheckIfExists(id int64) bool {
-	stmt := `SELECT EXISTS(SELECT FROM categories WHERE id = $1)`
+func (m *CategoryModel) Get(id int64) (*Category, error) {
+	stmt := `SELECT id, restaurant_id, brand_id, name, position, created_at FROM categories WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var ok bool
-	_ = m.DB.QueryRowContext(ctx, stmt, id).Scan(&ok)
-	if !ok {
-		return false
+	var category Category
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&category.ID, &category.RestaurantID, &category.BrandID, &category.Name, &category.Position, &category.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
-	return true
 
+	return &category, nil
+}
+
+func (m *CategoryModel) Update(category *Category) error {
+	stmt := `UPDATE categories SET name = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, category.Name, category.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the category together with its menu items.
+func (m *CategoryModel) Delete(id int64) error {
+	stmt := `DELETE FROM categories WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ReorderForRestaurant rewrites the positions of the restaurant's own
+// categories to the order of ids, which has to list every one of them.
+func (m *CategoryModel) ReorderForRestaurant(restaurantID int64, ids []int64) error {
+	return reorder(m.DB, "categories", "restaurant_id", restaurantID, ids)
+}
+
+// ReorderForBrand rewrites the positions of the brand's categories to the
+// order of ids, which has to list every one of them.
+func (m *CategoryModel) ReorderForBrand(brandID int64, ids []int64) error {
+	return reorder(m.DB, "categories", "brand_id", brandID, ids)
+}
+
+func ValidateCategory(v *validator.Validator, category Category) {
+	v.Check(v.Empty(category.Name), "name", "category name must be provided")
+	v.Check(len(category.Name) > 50, "name", "category name must not be more than 50 characters")
 }
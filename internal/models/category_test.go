@@ -0,0 +1,25 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCategory(t *testing.T) {
+
+	v := validator.New()
+	ValidateCategory(v, Category{Name: "Burgers"})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateCategory(v, Category{})
+	assert.Contains(t, v.FieldErorrs, "name")
+
+	v = validator.New()
+	ValidateCategory(v, Category{Name: strings.Repeat("a", 51)})
+	assert.Contains(t, v.FieldErorrs, "name")
+
+}
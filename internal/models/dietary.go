@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+// DietaryTags is the controlled vocabulary of tags a menu item can carry.
+var DietaryTags = []string{
+	"vegan",
+	"vegetarian",
+	"gluten_free",
+	"dairy_free",
+	"halal",
+	"kosher",
+	"spicy",
+}
+
+// Allergens are the 14 allergens that have to be declared in the EU.
+var Allergens = []string{
+	"celery",
+	"cereals_gluten",
+	"crustaceans",
+	"eggs",
+	"fish",
+	"lupin",
+	"milk",
+	"molluscs",
+	"mustard",
+	"nuts",
+	"peanuts",
+	"sesame",
+	"soya",
+	"sulphites",
+}
+
+// Nutrition facts are per portion, every value is optional.
+type Nutrition struct {
+	Kcal     *int     `json:"kcal,omitempty"`
+	ProteinG *float64 `json:"protein_g,omitempty"`
+	CarbsG   *float64 `json:"carbs_g,omitempty"`
+	FatG     *float64 `json:"fat_g,omitempty"`
+}
+
+func (n *Nutrition) empty() bool {
+	return n.Kcal == nil && n.ProteinG == nil && n.CarbsG == nil && n.FatG == nil
+}
+
+// nutritionFields returns the scan targets of the kcal, protein_g, carbs_g and
+// fat_g columns. setNutrition drops the nutrition again when all of them were null.
+func (menu *Menu) nutritionFields() []any {
+	menu.Nutrition = &Nutrition{}
+	return []any{&menu.Nutrition.Kcal, &menu.Nutrition.ProteinG, &menu.Nutrition.CarbsG, &menu.Nutrition.FatG}
+}
+
+func (menu *Menu) setNutrition() {
+	if menu.Nutrition != nil && menu.Nutrition.empty() {
+		menu.Nutrition = nil
+	}
+}
+
+func (menu *Menu) nutritionArgs() []any {
+	if menu.Nutrition == nil {
+		return []any{nil, nil, nil, nil}
+	}
+	return []any{menu.Nutrition.Kcal, menu.Nutrition.ProteinG, menu.Nutrition.CarbsG, menu.Nutrition.FatG}
+}
+
+// dietaryArgs returns the dietary_tags and allergens arguments, the columns don't take null.
+func (menu *Menu) dietaryArgs() []any {
+	if menu.DietaryTags == nil {
+		menu.DietaryTags = []string{}
+	}
+	if menu.Allergens == nil {
+		menu.Allergens = []string{}
+	}
+	return []any{pq.Array(menu.DietaryTags), pq.Array(menu.Allergens)}
+}
+
+// SetDietary replaces the dietary tags, allergens and nutrition facts of a menu item.
+func (m *MenuModel) SetDietary(menu *Menu) error {
+	stmt := `UPDATE menu SET dietary_tags = $1, allergens = $2, kcal = $3, protein_g = $4, carbs_g = $5, fat_g = $6 WHERE id = $7`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := append(menu.dietaryArgs(), menu.nutritionArgs()...)
+	args = append(args, menu.ID)
+
+	rows, err := m.DB.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func ValidateDietaryTags(v *validator.Validator, key string, tags []string) {
+	for _, tag := range tags {
+		v.Check(!slices.Contains(DietaryTags, tag), key, "unknown dietary tag "+tag)
+	}
+}
+
+func ValidateAllergens(v *validator.Validator, key string, allergens []string) {
+	for _, allergen := range allergens {
+		v.Check(!slices.Contains(Allergens, allergen), key, "unknown allergen "+allergen)
+	}
+}
+
+func ValidateDietary(v *validator.Validator, menu Menu) {
+	ValidateDietaryTags(v, "dietary_tags", menu.DietaryTags)
+	ValidateAllergens(v, "allergens", menu.Allergens)
+
+	if n := menu.Nutrition; n != nil {
+		v.Check(n.Kcal != nil && (*n.Kcal < 0 || *n.Kcal > 10000), "nutrition", "kcal must be between 0 and 10000")
+		for _, grams := range []*float64{n.ProteinG, n.CarbsG, n.FatG} {
+			v.Check(grams != nil && (*grams < 0 || *grams > 1000), "nutrition", "macros must be between 0 and 1000 grams")
+		}
+	}
+}
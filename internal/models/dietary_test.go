@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDietary(t *testing.T) {
+
+	kcal := 640
+	protein := 32.5
+	negative := -1.0
+
+	v := validator.New()
+	ValidateDietary(v, Menu{
+		DietaryTags: []string{"vegan", "gluten_free"},
+		Allergens:   []string{"soya", "sesame"},
+		Nutrition:   &Nutrition{Kcal: &kcal, ProteinG: &protein},
+	})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateDietary(v, Menu{DietaryTags: []string{"keto"}, Allergens: []string{"gluten"}})
+	assert.Contains(t, v.FieldErorrs, "dietary_tags")
+	assert.Contains(t, v.FieldErorrs, "allergens")
+
+	v = validator.New()
+	ValidateDietary(v, Menu{Nutrition: &Nutrition{FatG: &negative}})
+	assert.Contains(t, v.FieldErorrs, "nutrition")
+
+	assert.Len(t, Allergens, 14)
+
+}
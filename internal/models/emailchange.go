@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RequestEmailChange stores the address the user wants to switch to, it
+// replaces an earlier change that wasn't confirmed yet.
+func (m *UserModel) RequestEmailChange(userID int64, newEmail string) error {
+	stmt := `INSERT INTO email_changes (user_id, new_email) VALUES($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET new_email = EXCLUDED.new_email, created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, newEmail)
+	return err
+}
+
+// ConfirmEmailChange swaps the email of the user for the pending one and
+// returns it. The pending change is dropped even when the address was taken
+// by someone else in the meantime, in which case ErrDuplicateEmail is returned.
+func (m *UserModel) ConfirmEmailChange(userID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var newEmail string
+
+	err = tx.QueryRowContext(ctx, `DELETE FROM email_changes WHERE user_id = $1 RETURNING new_email`, userID).Scan(&newEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1 AND scope = $2`, userID, EmailChangeScope)
+	if err != nil {
+		return "", err
+	}
+
+	// a savepoint keeps the transaction usable when the unique constraint fails
+	_, err = tx.ExecContext(ctx, `SAVEPOINT swap_email`)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET email = $1, last_updated = NOW() WHERE id = $2`, newEmail, userID)
+	if err != nil {
+		if !strings.Contains(err.Error(), "users_email_key") {
+			return "", err
+		}
+
+		_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT swap_email`)
+		if err != nil {
+			return "", err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return "", err
+		}
+
+		return "", ErrDuplicateEmail
+	}
+
+	return newEmail, tx.Commit()
+}
@@ -0,0 +1,406 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+// openNowCondition is the SQL version of Schedule.IsOpenAt for the restaurant
+// aliased as r. A restaurant without opening hours follows its status only.
+const openNowCondition = `(r.status = 'open'
+	AND NOT EXISTS (SELECT FROM restaurant_closures rc WHERE rc.restaurant_id = r.id
+		AND (now() AT TIME ZONE r.timezone)::date BETWEEN rc.starts_on AND rc.ends_on)
+	AND (NOT EXISTS (SELECT FROM opening_hours oh WHERE oh.restaurant_id = r.id)
+		OR EXISTS (SELECT FROM opening_hours oh WHERE oh.restaurant_id = r.id AND (
+			(oh.opens_at < oh.closes_at AND oh.weekday = EXTRACT(DOW FROM now() AT TIME ZONE r.timezone)
+				AND (now() AT TIME ZONE r.timezone)::time >= oh.opens_at AND (now() AT TIME ZONE r.timezone)::time < oh.closes_at)
+			OR (oh.opens_at >= oh.closes_at AND (
+				(oh.weekday = EXTRACT(DOW FROM now() AT TIME ZONE r.timezone) AND (now() AT TIME ZONE r.timezone)::time >= oh.opens_at)
+				OR (oh.weekday = (EXTRACT(DOW FROM now() AT TIME ZONE r.timezone)::int + 6) % 7 AND (now() AT TIME ZONE r.timezone)::time < oh.closes_at)))))))`
+
+// OpeningHours is one opening period of a weekday (0 is Sunday). When ClosesAt
+// is not after OpensAt the period runs past midnight into the next day.
+type OpeningHours struct {
+	Weekday  int    `json:"weekday"`
+	OpensAt  string `json:"opens_at"`
+	ClosesAt string `json:"closes_at"`
+}
+
+type Closure struct {
+	ID           int64  `json:"id"`
+	RestaurantID int64  `json:"restaurant_id"`
+	StartsOn     string `json:"starts_on"`
+	EndsOn       string `json:"ends_on"`
+	Reason       string `json:"reason"`
+}
+
+type Schedule struct {
+	Timezone string          `json:"timezone"`
+	Hours    []*OpeningHours `json:"hours"`
+	Closures []*Closure      `json:"closures"`
+}
+
+type HoursModel struct {
+	DB *sql.DB
+}
+
+// parseClock turns "HH:MM" or "HH:MM:SS" into minutes after midnight.
+func parseClock(clock string) (int, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		t, err := time.Parse(layout, clock)
+		if err == nil {
+			return t.Hour()*60 + t.Minute(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q", clock)
+}
+
+func loadLocation(timezone string) *time.Location {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Location is the timezone of the schedule, UTC when it's unknown.
+func (s *Schedule) Location() *time.Location {
+	return loadLocation(s.Timezone)
+}
+
+// Location is the timezone of the restaurant, UTC when it's unknown.
+func (r *Restaurant) Location() *time.Location {
+	return loadLocation(r.Timezone)
+}
+
+func (s *Schedule) closedOn(day time.Time) bool {
+	date := day.Format(time.DateOnly)
+	for _, closure := range s.Closures {
+		if date >= closure.StartsOn && date <= closure.EndsOn {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpenAt reports whether the opening hours cover t and no closure applies to
+// that day. Without any opening hours the restaurant is considered open.
+func (s *Schedule) IsOpenAt(t time.Time) bool {
+	local := t.In(s.Location())
+
+	if s.closedOn(local) {
+		return false
+	}
+
+	if len(s.Hours) == 0 {
+		return true
+	}
+
+	minutes := local.Hour()*60 + local.Minute()
+	weekday := int(local.Weekday())
+	yesterday := (weekday + 6) % 7
+
+	for _, h := range s.Hours {
+		opens, err := parseClock(h.OpensAt)
+		if err != nil {
+			continue
+		}
+		closes, err := parseClock(h.ClosesAt)
+		if err != nil {
+			continue
+		}
+
+		if opens < closes {
+			if h.Weekday == weekday && minutes >= opens && minutes < closes {
+				return true
+			}
+			continue
+		}
+
+		if (h.Weekday == weekday && minutes >= opens) || (h.Weekday == yesterday && minutes < closes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextOpeningAt returns the next time after t the restaurant opens, looking two
+// weeks ahead. It returns nil when nothing is found.
+func (s *Schedule) NextOpeningAt(t time.Time) *time.Time {
+	loc := s.Location()
+	local := t.In(loc)
+
+	var next *time.Time
+	for offset := 0; offset < 14 && next == nil; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, loc)
+		if s.closedOn(day) {
+			continue
+		}
+
+		for _, h := range s.Hours {
+			if h.Weekday != int(day.Weekday()) {
+				continue
+			}
+
+			opens, err := parseClock(h.OpensAt)
+			if err != nil {
+				continue
+			}
+
+			candidate := time.Date(day.Year(), day.Month(), day.Day(), opens/60, opens%60, 0, 0, loc)
+			if candidate.After(t) && (next == nil || candidate.Before(*next)) {
+				next = &candidate
+			}
+		}
+	}
+
+	return next
+}
+
+// ApplySchedule fills the computed opening fields of the restaurant.
+func (r *Restaurant) ApplySchedule(s *Schedule, now time.Time) {
+	r.IsOpenNow = r.Status == "open" && s.IsOpenAt(now)
+	r.NextOpeningAt = nil
+
+	if !r.IsOpenNow && r.Status == "open" {
+		r.NextOpeningAt = s.NextOpeningAt(now)
+	}
+}
+
+func (m *HoursModel) GetSchedule(restaurantID int64) (*Schedule, error) {
+	schedules, err := m.GetSchedules([]int64{restaurantID})
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, ok := schedules[restaurantID]
+	if !ok {
+		return nil, ErrRestaurantNotFound
+	}
+
+	return schedule, nil
+}
+
+// GetSchedules loads the schedules of several restaurants with three queries.
+func (m *HoursModel) GetSchedules(restaurantIDs []int64) (map[int64]*Schedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	schedules := make(map[int64]*Schedule)
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT id, timezone FROM restaurant WHERE id = ANY($1)`, pq.Array(restaurantIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var id int64
+		schedule := &Schedule{Hours: []*OpeningHours{}, Closures: []*Closure{}}
+
+		err := rows.Scan(&id, &schedule.Timezone)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		schedules[id] = schedule
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT restaurant_id, weekday, to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI') FROM opening_hours
+	WHERE restaurant_id = ANY($1) ORDER BY weekday ASC, opens_at ASC`
+
+	rows, err = m.DB.QueryContext(ctx, stmt, pq.Array(restaurantIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var id int64
+		var h OpeningHours
+
+		err := rows.Scan(&id, &h.Weekday, &h.OpensAt, &h.ClosesAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if schedule, ok := schedules[id]; ok {
+			schedule.Hours = append(schedule.Hours, &h)
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// closures that are over don't matter anymore
+	stmt = `SELECT id, restaurant_id, to_char(starts_on, 'YYYY-MM-DD'), to_char(ends_on, 'YYYY-MM-DD'), reason FROM restaurant_closures
+	WHERE restaurant_id = ANY($1) AND ends_on >= CURRENT_DATE - 1 ORDER BY starts_on ASC`
+
+	rows, err = m.DB.QueryContext(ctx, stmt, pq.Array(restaurantIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var closure Closure
+
+		err := rows.Scan(&closure.ID, &closure.RestaurantID, &closure.StartsOn, &closure.EndsOn, &closure.Reason)
+		if err != nil {
+			return nil, err
+		}
+
+		if schedule, ok := schedules[closure.RestaurantID]; ok {
+			schedule.Closures = append(schedule.Closures, &closure)
+		}
+	}
+
+	return schedules, rows.Err()
+}
+
+// ReplaceHours sets the timezone and swaps all opening hours of the restaurant in one transaction.
+func (m *HoursModel) ReplaceHours(restaurantID int64, timezone string, hours []*OpeningHours) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE restaurant SET timezone = $1, updated_at = NOW() WHERE id = $2`, timezone, restaurantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRestaurantNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM opening_hours WHERE restaurant_id = $1`, restaurantID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO opening_hours (restaurant_id, weekday, opens_at, closes_at) VALUES($1, $2, $3, $4)`
+
+	for _, h := range hours {
+		_, err = tx.ExecContext(ctx, stmt, restaurantID, h.Weekday, h.OpensAt, h.ClosesAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *HoursModel) InsertClosure(closure *Closure) error {
+	stmt := `INSERT INTO restaurant_closures (restaurant_id, starts_on, ends_on, reason) VALUES($1, $2, $3, $4) RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, stmt, closure.RestaurantID, closure.StartsOn, closure.EndsOn, closure.Reason).Scan(&closure.ID)
+}
+
+func (m *HoursModel) DeleteClosure(restaurantID, id int64) error {
+	stmt := `DELETE FROM restaurant_closures WHERE id = $1 AND restaurant_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, id, restaurantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// IsOpenNow is used by the checkout to refuse orders for closed restaurants.
+func (m *HoursModel) IsOpenNow(restaurantID int64) (bool, error) {
+	stmt := `SELECT ` + openNowCondition + ` FROM restaurant r WHERE r.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var open bool
+	err := m.DB.QueryRowContext(ctx, stmt, restaurantID).Scan(&open)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrRestaurantNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return open, nil
+}
+
+func ValidateTimezone(v *validator.Validator, timezone string) {
+	_, err := time.LoadLocation(timezone)
+	v.Check(timezone == "" || err != nil, "timezone", "must be a valid IANA time zone like Europe/Berlin")
+}
+
+func ValidateOpeningHours(v *validator.Validator, hours []*OpeningHours) {
+	v.Check(len(hours) > 50, "hours", "must not contain more than 50 periods")
+
+	seen := make(map[string]bool)
+	for i, h := range hours {
+		key := fmt.Sprintf("hours.%d", i)
+
+		period := fmt.Sprintf("%d %s", h.Weekday, h.OpensAt)
+		v.Check(seen[period], key, "two periods can't open at the same time on the same weekday")
+		seen[period] = true
+
+		v.Check(h.Weekday < 0 || h.Weekday > 6, key, "weekday must be between 0 (sunday) and 6 (saturday)")
+
+		_, err := parseClock(h.OpensAt)
+		v.Check(err != nil, key, "opens_at must be a time like 09:30")
+
+		_, err = parseClock(h.ClosesAt)
+		v.Check(err != nil, key, "closes_at must be a time like 22:00")
+	}
+}
+
+func ValidateClosure(v *validator.Validator, closure Closure) {
+	startsOn, err := time.Parse(time.DateOnly, closure.StartsOn)
+	v.Check(err != nil, "starts_on", "must be a date in YYYY-MM-DD format")
+
+	endsOn, err := time.Parse(time.DateOnly, closure.EndsOn)
+	v.Check(err != nil, "ends_on", "must be a date in YYYY-MM-DD format")
+
+	v.Check(v.Valid() && endsOn.Before(startsOn), "ends_on", "must not be before starts_on")
+	v.Check(len(closure.Reason) > 200, "reason", "must not be more than 200 characters")
+}
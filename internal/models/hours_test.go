@@ -0,0 +1,104 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleIsOpenAt(t *testing.T) {
+
+	schedule := &Schedule{
+		Timezone: "Europe/Berlin",
+		Hours: []*OpeningHours{
+			// tuesday lunch and a friday night running past midnight
+			{Weekday: 2, OpensAt: "11:30", ClosesAt: "14:00"},
+			{Weekday: 5, OpensAt: "18:00", ClosesAt: "02:00"},
+		},
+		Closures: []*Closure{
+			{StartsOn: "2026-10-27", EndsOn: "2026-10-27"},
+		},
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	assert.True(t, schedule.IsOpenAt(time.Date(2026, 10, 20, 12, 0, 0, 0, berlin)))
+	assert.False(t, schedule.IsOpenAt(time.Date(2026, 10, 20, 14, 0, 0, 0, berlin)))
+	// 12:00 UTC is already 14:00 in Berlin
+	assert.False(t, schedule.IsOpenAt(time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)))
+
+	assert.True(t, schedule.IsOpenAt(time.Date(2026, 10, 23, 23, 0, 0, 0, berlin)))
+	assert.True(t, schedule.IsOpenAt(time.Date(2026, 10, 24, 1, 30, 0, 0, berlin)))
+	assert.False(t, schedule.IsOpenAt(time.Date(2026, 10, 24, 2, 0, 0, 0, berlin)))
+
+	// closed for the day even though it's a tuesday
+	assert.False(t, schedule.IsOpenAt(time.Date(2026, 10, 27, 12, 0, 0, 0, berlin)))
+
+	assert.True(t, (&Schedule{Timezone: "UTC"}).IsOpenAt(time.Now()))
+
+}
+
+func TestScheduleNextOpeningAt(t *testing.T) {
+
+	schedule := &Schedule{
+		Timezone: "UTC",
+		Hours: []*OpeningHours{
+			{Weekday: 2, OpensAt: "11:30", ClosesAt: "14:00"},
+		},
+		Closures: []*Closure{
+			{StartsOn: "2026-10-27", EndsOn: "2026-10-27"},
+		},
+	}
+
+	next := schedule.NextOpeningAt(time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC))
+	require.NotNil(t, next)
+	assert.Equal(t, time.Date(2026, 10, 20, 11, 30, 0, 0, time.UTC), *next)
+
+	// the next tuesday is a closure so it's the one after
+	next = schedule.NextOpeningAt(time.Date(2026, 10, 21, 12, 0, 0, 0, time.UTC))
+	require.NotNil(t, next)
+	assert.Equal(t, time.Date(2026, 11, 3, 11, 30, 0, 0, time.UTC), *next)
+
+	assert.Nil(t, (&Schedule{Timezone: "UTC"}).NextOpeningAt(time.Now()))
+
+}
+
+func TestApplySchedule(t *testing.T) {
+
+	schedule := &Schedule{Timezone: "UTC", Hours: []*OpeningHours{{Weekday: 2, OpensAt: "11:30", ClosesAt: "14:00"}}}
+	now := time.Date(2026, 10, 20, 15, 0, 0, 0, time.UTC)
+
+	restaurant := &Restaurant{Status: "open"}
+	restaurant.ApplySchedule(schedule, now)
+	assert.False(t, restaurant.IsOpenNow)
+	assert.NotNil(t, restaurant.NextOpeningAt)
+
+	restaurant = &Restaurant{Status: "closed"}
+	restaurant.ApplySchedule(schedule, now.Add(-2*time.Hour))
+	assert.False(t, restaurant.IsOpenNow)
+	assert.Nil(t, restaurant.NextOpeningAt)
+
+}
+
+func TestValidateOpeningHours(t *testing.T) {
+
+	v := validator.New()
+	ValidateOpeningHours(v, []*OpeningHours{{Weekday: 1, OpensAt: "09:00", ClosesAt: "17:00"}})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateOpeningHours(v, []*OpeningHours{{Weekday: 7, OpensAt: "9am", ClosesAt: "17:00"}})
+	assert.False(t, v.Valid())
+
+	v = validator.New()
+	ValidateOpeningHours(v, []*OpeningHours{
+		{Weekday: 1, OpensAt: "09:00", ClosesAt: "12:00"},
+		{Weekday: 1, OpensAt: "09:00", ClosesAt: "17:00"},
+	})
+	assert.False(t, v.Valid())
+
+}
@@ -3,44 +3,72 @@ package models
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
 )
 
 type Menu struct {
-	ID             int64     `json:"id"`
-	CategoryID     int64     `json:"category_id,omitempty"`
-	Name           string    `json:"name"`
-	Description    string    `json:"description"`
-	RestaurantName string    `json:"restaurant_name"`
-	PriceCent      float32   `json:"price_cent"`
-	IsAvaiable     bool      `json:"is_available"`
-	CreatedAt      time.Time `json:"-"`
+	ID             int64          `json:"id"`
+	CategoryID     int64          `json:"category_id,omitempty"`
+	Name           string         `json:"name"`
+	Description    string         `json:"description"`
+	RestaurantName string         `json:"restaurant_name"`
+	PriceCent      float32        `json:"price_cent"`
+	IsAvaiable     bool           `json:"is_available"`
+	DietaryTags    []string       `json:"dietary_tags"`
+	Allergens      []string       `json:"allergens"`
+	Nutrition      *Nutrition     `json:"nutrition,omitempty"`
+	OptionGroups   []*OptionGroup `json:"option_groups,omitempty"`
+	Position       int            `json:"position"`
+	CreatedAt      time.Time      `json:"-"`
 }
 
 type MenuWithCategoryName struct {
 	Menu
 	CategoryName string `json:"category_name"`
+	Inherited    bool   `json:"inherited,omitempty"`
+}
+
+// MenuOverride changes the price or the availability of a brand menu item at
+// one branch, nil fields keep the value of the brand.
+type MenuOverride struct {
+	RestaurantID int64     `json:"restaurant_id"`
+	MenuID       int64     `json:"menu_id"`
+	PriceCent    *int64    `json:"price_cent,omitempty"`
+	IsAvailable  *bool     `json:"is_available,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type MenuModel struct {
 	DB *sql.DB
 }
 
+// Insert puts the menu item after the other items of its category.
 func (m *MenuModel) Insert(menu *Menu) error {
-	stmt := `INSERT INTO menu (category_id, name, description, price_cent) VALUES($1, $2, $3, $4) RETURNING id, is_available, created_at`
+	stmt := `INSERT INTO menu (category_id, name, description, price_cent, dietary_tags, allergens, kcal, protein_g, carbs_g, fat_g, position)
+	SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE(MAX(position), 0) + 1 FROM menu WHERE category_id = $1
+	RETURNING id, is_available, position, created_at`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	args := []any{menu.CategoryID, menu.Name, menu.Description, menu.PriceCent}
+	args = append(args, menu.dietaryArgs()...)
+	args = append(args, menu.nutritionArgs()...)
 
-	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&menu.ID, &menu.IsAvaiable, &menu.CreatedAt)
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&menu.ID, &menu.IsAvaiable, &menu.Position, &menu.CreatedAt)
 	return err
 
 }
 
-func (m *MenuModel) GetAll(name string, f Filters) ([]*Menu, Metadata, error) {
+// GetAll searches the menus of the approved restaurants and of the brands by
+// name. Only menus carrying every tag of tags are returned, and menus
+// containing any of the excludedAllergens are left out.
+func (m *MenuModel) GetAll(name string, tags, excludedAllergens []string, f Filters) ([]*Menu, Metadata, error) {
 
 	sortColumn := f.sortColumn()
 	safeSortColumn := "m.id" // Default fallback
@@ -57,16 +85,28 @@ func (m *MenuModel) GetAll(name string, f Filters) ([]*Menu, Metadata, error) {
 		// Add other cases here
 	}
 
-	stmt := fmt.Sprintf(`SELECT count(*) OVER(), m.id, m.category_id, r.name, m.name, m.description, m.price_cent, m.is_available, m.created_at FROM menu m
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), m.id, m.category_id, COALESCE(r.name, b.name), m.name, m.description, m.price_cent, m.is_available, m.position, m.dietary_tags, m.allergens,
+	m.kcal, m.protein_g, m.carbs_g, m.fat_g, m.created_at FROM menu m
 	INNER JOIN categories c on c.id = m.category_id
-	INNER JOIN restaurant r on r.id = c.restaurant_id
-	WHERE (to_tsvector('simple', m.name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	LEFT JOIN restaurant r on r.id = c.restaurant_id
+	LEFT JOIN brands b on b.id = c.brand_id
+	WHERE (r.review_status = 'approved' OR c.brand_id IS NOT NULL)
+	AND (to_tsvector('simple', m.name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	AND m.dietary_tags @> $2
+	AND NOT m.allergens && $3
 	ORDER BY %s %s, m.id ASC LIMIT %d OFFSET %d`, safeSortColumn, f.sortDirection(), f.Limit(), f.Offset())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, stmt, name)
+	if tags == nil {
+		tags = []string{}
+	}
+	if excludedAllergens == nil {
+		excludedAllergens = []string{}
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, name, pq.Array(tags), pq.Array(excludedAllergens))
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -76,10 +116,14 @@ func (m *MenuModel) GetAll(name string, f Filters) ([]*Menu, Metadata, error) {
 	for rows.Next() {
 		var menu Menu
 
-		err := rows.Scan(&totalRecords, &menu.ID, &menu.CategoryID, &menu.RestaurantName, &menu.Name, &menu.Description, &menu.PriceCent, &menu.IsAvaiable, &menu.CreatedAt)
+		dest := []any{&totalRecords, &menu.ID, &menu.CategoryID, &menu.RestaurantName, &menu.Name, &menu.Description, &menu.PriceCent, &menu.IsAvaiable, &menu.Position, pq.Array(&menu.DietaryTags), pq.Array(&menu.Allergens)}
+		dest = append(dest, menu.nutritionFields()...)
+
+		err := rows.Scan(append(dest, &menu.CreatedAt)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
+		menu.setNutrition()
 		menus = append(menus, &menu)
 	}
 
@@ -89,11 +133,17 @@ func (m *MenuModel) GetAll(name string, f Filters) ([]*Menu, Metadata, error) {
 
 }
 
+// GetRestaurantMenus returns the effective menu of the restaurant: its own
+// menus and the ones of its brand, with the price and the availability the
+// restaurant overrides for them.
 func (m *MenuModel) GetRestaurantMenus(id int64) ([]*MenuWithCategoryName, error) {
-	stmt := `SELECT m.id, m.name, c.name, r.name, m.description, m.price_cent, m.is_available from menu m
+	stmt := `SELECT m.id, m.name, c.name, r.name, m.description, COALESCE(mo.price_cent, m.price_cent), COALESCE(mo.is_available, m.is_available), c.brand_id IS NOT NULL,
+	m.position, m.dietary_tags, m.allergens, m.kcal, m.protein_g, m.carbs_g, m.fat_g from menu m
 	INNER JOIN categories c on c.id = m.category_id
-	INNER JOIN restaurant r on r.id = c.restaurant_id
-	WHERE c.restaurant_id = $1`
+	INNER JOIN restaurant r on r.id = c.restaurant_id OR r.brand_id = c.brand_id
+	LEFT JOIN menu_overrides mo on mo.menu_id = m.id AND mo.restaurant_id = r.id
+	WHERE r.id = $1
+	ORDER BY c.brand_id IS NULL, c.position, c.id, m.position, m.id`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -108,18 +158,36 @@ func (m *MenuModel) GetRestaurantMenus(id int64) ([]*MenuWithCategoryName, error
 	var menus []*MenuWithCategoryName
 	for rows.Next() {
 		var menu MenuWithCategoryName
-		err := rows.Scan(&menu.ID, &menu.Name, &menu.CategoryName, &menu.RestaurantName, &menu.Description, &menu.PriceCent, &menu.IsAvaiable)
+		dest := []any{&menu.ID, &menu.Name, &menu.CategoryName, &menu.RestaurantName, &menu.Description, &menu.PriceCent, &menu.IsAvaiable, &menu.Inherited, &menu.Position, pq.Array(&menu.DietaryTags), pq.Array(&menu.Allergens)}
+
+		err := rows.Scan(append(dest, menu.nutritionFields()...)...)
 		if err != nil {
 			return nil, err
 		}
+		menu.setNutrition()
 		menus = append(menus, &menu)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	embedded := make([]*Menu, 0, len(menus))
+	for _, menu := range menus {
+		embedded = append(embedded, &menu.Menu)
+	}
+
+	err = attachOptionGroups(ctx, m.DB, embedded)
+	if err != nil {
+		return nil, err
+	}
+
 	return menus, nil
 
 }
 func (m *MenuModel) GetAllMenuForCategory(id int64) ([]*Menu, error) {
-	stmt := `SELECT id, category_id, name, description, price_cent, is_available from menu WHERE category_id = $1`
+	stmt := `SELECT id, category_id, name, description, price_cent, is_available, position, dietary_tags, allergens, kcal, protein_g, carbs_g, fat_g from menu WHERE category_id = $1
+	ORDER BY position, id`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -134,13 +202,264 @@ func (m *MenuModel) GetAllMenuForCategory(id int64) ([]*Menu, error) {
 	var menus []*Menu
 	for rows.Next() {
 		var menu Menu
-		err := rows.Scan(&menu.ID, &menu.CategoryID, &menu.Name, &menu.Description, &menu.PriceCent, &menu.IsAvaiable)
+		dest := []any{&menu.ID, &menu.CategoryID, &menu.Name, &menu.Description, &menu.PriceCent, &menu.IsAvaiable, &menu.Position, pq.Array(&menu.DietaryTags), pq.Array(&menu.Allergens)}
+
+		err := rows.Scan(append(dest, menu.nutritionFields()...)...)
 		if err != nil {
 			return nil, err
 		}
+		menu.setNutrition()
 		menus = append(menus, &menu)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = attachOptionGroups(ctx, m.DB, menus)
+	if err != nil {
+		return nil, err
+	}
+
 	return menus, nil
 
 }
+
+func (m *MenuModel) Get(id int64) (*Menu, error) {
+	stmt := `SELECT id, category_id, name, description, price_cent, is_available, position, dietary_tags, allergens, kcal, protein_g, carbs_g, fat_g, created_at FROM menu WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var menu Menu
+	dest := []any{&menu.ID, &menu.CategoryID, &menu.Name, &menu.Description, &menu.PriceCent, &menu.IsAvaiable, &menu.Position, pq.Array(&menu.DietaryTags), pq.Array(&menu.Allergens)}
+	dest = append(dest, menu.nutritionFields()...)
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(append(dest, &menu.CreatedAt)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	menu.setNutrition()
+
+	err = attachOptionGroups(ctx, m.DB, []*Menu{&menu})
+	if err != nil {
+		return nil, err
+	}
+
+	return &menu, nil
+}
+
+// Update changes the name, the description, the price and the category of the
+// menu item. The dietary information is changed with SetDietary. An item moved
+// to another category goes to the end of it, and the branch overrides and cart
+// lines of restaurants that don't offer it anymore are dropped.
+func (m *MenuModel) Update(menu *Menu) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE menu SET name = $1, description = $2, price_cent = $3, category_id = $4,
+	position = CASE WHEN category_id = $4 THEN position ELSE (SELECT COALESCE(MAX(position), 0) + 1 FROM menu WHERE category_id = $4) END
+	WHERE id = $5 RETURNING position`
+
+	err = tx.QueryRowContext(ctx, stmt, menu.Name, menu.Description, menu.PriceCent, menu.CategoryID, menu.ID).Scan(&menu.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// only the branches of the brand the item now belongs to can override it
+	stmt = `DELETE FROM menu_overrides o WHERE o.menu_id = $1 AND NOT EXISTS (
+		SELECT FROM categories c INNER JOIN restaurant r ON r.brand_id = c.brand_id WHERE c.id = $2 AND r.id = o.restaurant_id)`
+
+	_, err = tx.ExecContext(ctx, stmt, menu.ID, menu.CategoryID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `DELETE FROM cart_items ci WHERE ci.menu_id = $1 AND NOT EXISTS (
+		SELECT FROM categories c LEFT JOIN restaurant r ON r.id = ci.restaurant_id
+		WHERE c.id = $2 AND (c.restaurant_id = ci.restaurant_id OR c.brand_id = r.brand_id))`
+
+	_, err = tx.ExecContext(ctx, stmt, menu.ID, menu.CategoryID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetAvailability marks the menu item as sold out or available again, the
+// overrides of the branches still take precedence for brand items.
+func (m *MenuModel) SetAvailability(id int64, available bool) error {
+	stmt := `UPDATE menu SET is_available = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, available, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the menu item, the orders keep their lines without the link to it.
+func (m *MenuModel) Delete(id int64) error {
+	stmt := `DELETE FROM menu WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Reorder rewrites the positions of the menu items of the category to the
+// order of ids, which has to list every one of them.
+func (m *MenuModel) Reorder(categoryID int64, ids []int64) error {
+	return reorder(m.DB, "menu", "category_id", categoryID, ids)
+}
+
+// GetCategory returns the category of a menu item, which tells the restaurant
+// or the brand the item belongs to.
+func (m *MenuModel) GetCategory(menuID int64) (*Category, error) {
+	stmt := `SELECT c.id, c.restaurant_id, c.brand_id, c.name, c.position, c.created_at FROM menu m INNER JOIN categories c on c.id = m.category_id WHERE m.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var category Category
+	err := m.DB.QueryRowContext(ctx, stmt, menuID).Scan(&category.ID, &category.RestaurantID, &category.BrandID, &category.Name, &category.Position, &category.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &category, nil
+}
+
+// OfferedAt reports whether the menu item is on the menu of the restaurant,
+// directly or through the restaurant's brand.
+func (m *MenuModel) OfferedAt(menuID, restaurantID int64) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT FROM menu m
+	INNER JOIN categories c on c.id = m.category_id
+	INNER JOIN restaurant r on r.id = c.restaurant_id OR r.brand_id = c.brand_id
+	WHERE m.id = $1 AND r.id = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var offered bool
+	err := m.DB.QueryRowContext(ctx, stmt, menuID, restaurantID).Scan(&offered)
+	return offered, err
+}
+
+// SetOverride creates or replaces the override of a brand menu item at one of
+// the brand's branches, ErrRecordNotFound when the menu item isn't one of the
+// restaurant's brand.
+func (m *MenuModel) SetOverride(override *MenuOverride) error {
+	stmt := `INSERT INTO menu_overrides (restaurant_id, menu_id, price_cent, is_available)
+	SELECT r.id, m.id, $3, $4 FROM menu m
+	INNER JOIN categories c on c.id = m.category_id
+	INNER JOIN restaurant r on r.brand_id = c.brand_id
+	WHERE r.id = $1 AND m.id = $2
+	ON CONFLICT (restaurant_id, menu_id) DO UPDATE SET price_cent = EXCLUDED.price_cent, is_available = EXCLUDED.is_available, updated_at = NOW()
+	RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, override.RestaurantID, override.MenuID, override.PriceCent, override.IsAvailable).Scan(&override.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteOverride brings the brand's price and availability of the menu item
+// back to the restaurant.
+func (m *MenuModel) DeleteOverride(restaurantID, menuID int64) error {
+	stmt := `DELETE FROM menu_overrides WHERE restaurant_id = $1 AND menu_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, restaurantID, menuID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func ValidateMenuOverride(v *validator.Validator, override MenuOverride) {
+	v.Check(override.PriceCent == nil && override.IsAvailable == nil, "override", "price_cent or is_available must be provided")
+	v.Check(override.PriceCent != nil && *override.PriceCent < 0, "price_cent", "price_cent must not be negative")
+}
+
+func ValidateMenu(v *validator.Validator, menu Menu) {
+	v.Check(v.Empty(menu.Name), "name", "menu name must be provided")
+	v.Check(len(menu.Name) > 100, "name", "menu name must not be more than 100 characters")
+	v.Check(len(menu.Description) > 1000, "description", "description must not be more than 1000 characters")
+	v.Check(menu.PriceCent < 0, "price_cent", "price_cent must not be negative")
+	v.Check(menu.PriceCent != float32(int64(menu.PriceCent)), "price_cent", "price_cent must be a whole number of cents")
+
+	ValidateDietary(v, menu)
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMenu(t *testing.T) {
+
+	v := validator.New()
+	ValidateMenu(v, Menu{Name: "Cheeseburger", PriceCent: 950, DietaryTags: []string{"halal"}})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateMenu(v, Menu{PriceCent: 950})
+	assert.Contains(t, v.FieldErorrs, "name")
+
+	v = validator.New()
+	ValidateMenu(v, Menu{Name: "Cheeseburger", PriceCent: -1})
+	assert.Contains(t, v.FieldErorrs, "price_cent")
+
+	v = validator.New()
+	ValidateMenu(v, Menu{Name: "Cheeseburger", PriceCent: 9.5})
+	assert.Equal(t, "price_cent must be a whole number of cents", v.FieldErorrs["price_cent"])
+
+	v = validator.New()
+	ValidateMenu(v, Menu{Name: "Cheeseburger", PriceCent: 950, Allergens: []string{"bacon"}})
+	assert.Contains(t, v.FieldErorrs, "allergens")
+
+}
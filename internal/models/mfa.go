@@ -0,0 +1,321 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+// MFARequiredSetting is the settings row telling whether users with the
+// restaurant:write permission must have two-factor authentication enabled.
+const MFARequiredSetting = "mfa_required_for_sellers"
+
+// RecoveryCodeCount is how many recovery codes are handed out at a time.
+const RecoveryCodeCount = 10
+
+// TOTP is the authenticator app enrolment of a user, it is only used for the
+// login once the first code was verified and Enabled is set.
+type TOTP struct {
+	UserID   int64
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+type MFAModel struct {
+	DB *sql.DB
+}
+
+func (m *MFAModel) Get(userID int64) (*TOTP, error) {
+	stmt := `SELECT user_id, secret, enabled, last_step FROM user_totp WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var totp TOTP
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(&totp.UserID, &totp.Secret, &totp.Enabled, &totp.LastStep)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &totp, nil
+}
+
+// IsEnabled reports whether the user has to give a code to log in.
+func (m *MFAModel) IsEnabled(userID int64) (bool, error) {
+	totp, err := m.Get(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return totp.Enabled, nil
+}
+
+// StartEnrolment stores a new secret waiting for its first code, an earlier
+// unfinished enrolment is replaced.
+func (m *MFAModel) StartEnrolment(userID int64, secret string) error {
+	stmt := `INSERT INTO user_totp (user_id, secret) VALUES($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = NOW()
+	WHERE NOT user_totp.enabled`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// Enable finishes the enrolment and stores the recovery codes.
+func (m *MFAModel) Enable(userID, step int64, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.ExecContext(ctx, `UPDATE user_totp SET enabled = true, last_step = $1 WHERE user_id = $2 AND NOT enabled`, step, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	err = insertRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records the time step of an accepted code. It fails with
+// ErrRecordNotFound when a concurrent request already used the step.
+func (m *MFAModel) UseStep(userID, step int64) error {
+	stmt := `UPDATE user_totp SET last_step = $1 WHERE user_id = $2 AND last_step < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, step, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Disable removes the enrolment and the recovery codes of the user.
+func (m *MFAModel) Disable(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{`DELETE FROM recovery_codes WHERE user_id = $1`, `DELETE FROM user_totp WHERE user_id = $1`} {
+		_, err = tx.ExecContext(ctx, stmt, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates the remaining recovery codes of the user and stores new ones.
+func (m *MFAModel) ReplaceRecoveryCodes(userID int64, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks the recovery code as used, it reports false when the
+// code is unknown or was used before.
+func (m *MFAModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	stmt := `UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND hash = $2 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// IsRequired reports whether sellers must use two-factor authentication.
+func (m *MFAModel) IsRequired() (bool, error) {
+	stmt := `SELECT COALESCE((SELECT value::boolean FROM settings WHERE name = $1), false)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var required bool
+
+	err := m.DB.QueryRowContext(ctx, stmt, MFARequiredSetting).Scan(&required)
+	if err != nil {
+		return false, err
+	}
+
+	return required, nil
+}
+
+func (m *MFAModel) SetRequired(required bool) error {
+	stmt := `INSERT INTO settings (name, value) VALUES($1, $2)
+	ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	value := "false"
+	if required {
+		value = "true"
+	}
+
+	_, err := m.DB.ExecContext(ctx, stmt, MFARequiredSetting, value)
+	return err
+}
+
+// MissingRequired reports whether two-factor authentication is required for
+// sellers but the user hasn't enabled it. Callers check the permission.
+func (m *MFAModel) MissingRequired(userID int64) (bool, error) {
+	stmt := `SELECT COALESCE((SELECT value::boolean FROM settings WHERE name = $1), false)
+	AND NOT EXISTS(SELECT FROM user_totp WHERE user_id = $2 AND enabled)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var missing bool
+
+	err := m.DB.QueryRowContext(ctx, stmt, MFARequiredSetting, userID).Scan(&missing)
+	if err != nil {
+		return false, err
+	}
+
+	return missing, nil
+}
+
+// GenerateRecoveryCodes returns new recovery codes in the xxxxx-xxxxx form.
+func GenerateRecoveryCodes() ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, RecoveryCodeCount)
+
+	for i := range codes {
+		randomBytes := make([]byte, 7)
+
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(randomBytes))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode hashes the code like the tokens are hashed, ignoring case,
+// spaces and dashes users may type differently.
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	hash := sha256.Sum256([]byte(code))
+
+	return hash[:]
+}
+
+func insertRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, hash) VALUES($1, $2)`, userID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ValidateTOTPCode(v *validator.Validator, code string) {
+	v.Check(v.Empty(code), "code", "code must be provided")
+	v.Check(len(code) != 6, "code", "code must be 6 digits long")
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+
+	codes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+
+	hash := hashRecoveryCode("abcde-fghij")
+
+	assert.Equal(t, hash, hashRecoveryCode("ABCDE FGHIJ"))
+	assert.Equal(t, hash, hashRecoveryCode("abcdefghij"))
+	assert.NotEqual(t, hash, hashRecoveryCode("abcde-fghik"))
+
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+
+	v := validator.New()
+	ValidateTOTPCode(v, "123456")
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateTOTPCode(v, "1234")
+	assert.Contains(t, v.FieldErorrs, "code")
+
+}
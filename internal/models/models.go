@@ -11,24 +11,71 @@ var (
 	ErrConflictEdit            = errors.New("conflict edit")
 	ErrDuplicateRestaurantName = errors.New("duplicate restaurant name")
 	ErrRestaurantNotFound      = errors.New("no restaurant found")
+	ErrInvalidTransition       = errors.New("invalid order status transition")
+	ErrEmptyCart               = errors.New("cart is empty")
+	ErrMenuUnavailable         = errors.New("menu item is not available")
+	ErrMixedRestaurantCart     = errors.New("cart can only contain items from one restaurant")
+	ErrInvalidRefund           = errors.New("refund exceeds what is left to refund")
+	ErrPaymentNotCaptured      = errors.New("the payment of this order was not captured yet")
+	ErrDuplicateTableName      = errors.New("duplicate table name")
+	ErrNoTableAvailable        = errors.New("no table available for this slot")
+	ErrTableHasReservations    = errors.New("the table has upcoming reservations, cancel them first")
+	ErrCartQuantityExceeded    = errors.New("quantity must not be more than 100 together with what is already in the cart")
+	ErrDuplicateReview         = errors.New("you already reviewed this")
+	ErrTokenReused             = errors.New("refresh token was already used")
+	ErrMFAAlreadyEnabled       = errors.New("two-factor authentication is already enabled")
+	ErrDuplicateRoleName       = errors.New("duplicate role name")
+	ErrPermissionFromRole      = errors.New("take the role away instead")
+	ErrLastOwner               = errors.New("there has to be at least one owner")
+	ErrDuplicateBrandName      = errors.New("duplicate brand name")
+	ErrRestaurantHasBrand      = errors.New("the restaurant already belongs to a brand")
+	ErrBranchRequired          = errors.New("the restaurant to order this brand item from must be provided")
+	ErrUserHasRestaurant       = errors.New("the user already has a restaurant")
+	ErrInvalidOrder            = errors.New("the order has to list every item exactly once")
 )
 
 type Models struct {
-	Users       *UserModel
-	Restaurants *RestaurantModel
-	Tokens      *TokenModel
-	Permissions *PermissionModel
-	Categories  *CategoryModel
-	Menu        *MenuModel
+	Users        *UserModel
+	Restaurants  *RestaurantModel
+	Tokens       *TokenModel
+	Permissions  *PermissionModel
+	Categories   *CategoryModel
+	Menu         *MenuModel
+	Carts        *CartModel
+	Orders       *OrderModel
+	Payments     *PaymentModel
+	Refunds      *RefundModel
+	Tables       *TableModel
+	Reservations *ReservationModel
+	Hours        *HoursModel
+	OptionGroups *OptionGroupModel
+	Reviews      *ReviewModel
+	MFA          *MFAModel
+	Roles        *RoleModel
+	Staff        *StaffModel
+	Brands       *BrandModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:       &UserModel{DB: db},
-		Restaurants: &RestaurantModel{DB: db},
-		Tokens:      &TokenModel{DB: db},
-		Permissions: &PermissionModel{DB: db},
-		Categories:  &CategoryModel{DB: db},
-		Menu:        &MenuModel{DB: db},
+		Users:        &UserModel{DB: db},
+		Restaurants:  &RestaurantModel{DB: db},
+		Tokens:       &TokenModel{DB: db},
+		Permissions:  &PermissionModel{DB: db},
+		Categories:   &CategoryModel{DB: db},
+		Menu:         &MenuModel{DB: db},
+		Carts:        &CartModel{DB: db},
+		Orders:       &OrderModel{DB: db},
+		Payments:     &PaymentModel{DB: db},
+		Refunds:      &RefundModel{DB: db},
+		Tables:       &TableModel{DB: db},
+		Reservations: &ReservationModel{DB: db},
+		Hours:        &HoursModel{DB: db},
+		OptionGroups: &OptionGroupModel{DB: db},
+		Reviews:      &ReviewModel{DB: db},
+		MFA:          &MFAModel{DB: db},
+		Roles:        &RoleModel{DB: db},
+		Staff:        &StaffModel{DB: db},
+		Brands:       &BrandModel{DB: db},
 	}
 }
@@ -0,0 +1,287 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	SelectionSingle = "single"
+	SelectionMulti  = "multi"
+)
+
+// OptionGroup is a set of choices attached to a menu item, like "size" or
+// "extras". The customer has to pick between MinChoices and MaxChoices options
+// of the group, a required group needs at least one.
+type OptionGroup struct {
+	ID         int64     `json:"id"`
+	MenuID     int64     `json:"menu_id"`
+	Name       string    `json:"name"`
+	Selection  string    `json:"selection"`
+	MinChoices int       `json:"min_choices"`
+	MaxChoices int       `json:"max_choices"`
+	Required   bool      `json:"required"`
+	Options    []*Option `json:"options"`
+}
+
+type Option struct {
+	ID             int64  `json:"id"`
+	GroupID        int64  `json:"group_id"`
+	Name           string `json:"name"`
+	PriceDeltaCent int64  `json:"price_delta_cent"`
+	IsAvailable    bool   `json:"is_available"`
+}
+
+type OptionGroupModel struct {
+	DB *sql.DB
+}
+
+// queryer lets the option lookups run on the database or inside a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Insert creates the group together with its options in one transaction.
+func (m *OptionGroupModel) Insert(group *OptionGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO option_groups (menu_id, name, selection, min_choices, max_choices, required) VALUES($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	args := []any{group.MenuID, group.Name, group.Selection, group.MinChoices, group.MaxChoices, group.Required}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&group.ID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO options (group_id, name, price_delta_cent) VALUES($1, $2, $3) RETURNING id, is_available`
+
+	for _, option := range group.Options {
+		option.GroupID = group.ID
+
+		err = tx.QueryRowContext(ctx, stmt, option.GroupID, option.Name, option.PriceDeltaCent).Scan(&option.ID, &option.IsAvailable)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *OptionGroupModel) GetAllForMenu(menuID int64) ([]*OptionGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	groups, err := getOptionGroups(ctx, m.DB, []int64{menuID})
+	if err != nil {
+		return nil, err
+	}
+
+	return groups[menuID], nil
+}
+
+func (m *OptionGroupModel) Delete(menuID, id int64) error {
+	stmt := `DELETE FROM option_groups WHERE id = $1 AND menu_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, id, menuID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m *OptionGroupModel) GetOption(menuID, id int64) (*Option, error) {
+	stmt := `SELECT o.id, o.group_id, o.name, o.price_delta_cent, o.is_available FROM options o
+	INNER JOIN option_groups g on g.id = o.group_id
+	WHERE o.id = $1 AND g.menu_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var option Option
+
+	err := m.DB.QueryRowContext(ctx, stmt, id, menuID).Scan(&option.ID, &option.GroupID, &option.Name, &option.PriceDeltaCent, &option.IsAvailable)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &option, nil
+}
+
+func (m *OptionGroupModel) UpdateOption(option *Option) error {
+	stmt := `UPDATE options SET name = $1, price_delta_cent = $2, is_available = $3 WHERE id = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, option.Name, option.PriceDeltaCent, option.IsAvailable, option.ID)
+	return err
+}
+
+// getOptionGroups loads the option groups of several menu items, keyed by menu id.
+func getOptionGroups(ctx context.Context, q queryer, menuIDs []int64) (map[int64][]*OptionGroup, error) {
+	stmt := `SELECT g.id, g.menu_id, g.name, g.selection, g.min_choices, g.max_choices, g.required,
+	o.id, o.name, o.price_delta_cent, o.is_available FROM option_groups g
+	LEFT JOIN options o on o.group_id = g.id
+	WHERE g.menu_id = ANY($1)
+	ORDER BY g.id ASC, o.id ASC`
+
+	rows, err := q.QueryContext(ctx, stmt, pq.Array(menuIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	groups := make(map[int64][]*OptionGroup)
+	var current *OptionGroup
+
+	for rows.Next() {
+		var group OptionGroup
+		var optionID, priceDelta sql.NullInt64
+		var optionName sql.NullString
+		var optionAvailable sql.NullBool
+
+		err := rows.Scan(&group.ID, &group.MenuID, &group.Name, &group.Selection, &group.MinChoices, &group.MaxChoices, &group.Required,
+			&optionID, &optionName, &priceDelta, &optionAvailable)
+		if err != nil {
+			return nil, err
+		}
+
+		if current == nil || current.ID != group.ID {
+			current = &group
+			current.Options = []*Option{}
+			groups[group.MenuID] = append(groups[group.MenuID], current)
+		}
+
+		if optionID.Valid {
+			current.Options = append(current.Options, &Option{
+				ID:             optionID.Int64,
+				GroupID:        current.ID,
+				Name:           optionName.String,
+				PriceDeltaCent: priceDelta.Int64,
+				IsAvailable:    optionAvailable.Bool,
+			})
+		}
+	}
+
+	return groups, rows.Err()
+}
+
+// attachOptionGroups fills the option groups of the menus.
+func attachOptionGroups(ctx context.Context, q queryer, menus []*Menu) error {
+	ids := make([]int64, 0, len(menus))
+	for _, menu := range menus {
+		ids = append(ids, menu.ID)
+	}
+
+	groups, err := getOptionGroups(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, menu := range menus {
+		menu.OptionGroups = groups[menu.ID]
+	}
+
+	return nil
+}
+
+func ValidateOptionGroup(v *validator.Validator, group OptionGroup) {
+	v.Check(v.Empty(group.Name), "name", "option group name must be provided")
+	v.Check(len(group.Name) > 100, "name", "option group name must be less than 100 characters")
+	v.Check(!validator.PermittedValue(group.Selection, SelectionSingle, SelectionMulti), "selection", "selection must be single or multi")
+	v.Check(group.MinChoices < 0, "min_choices", "min choices must not be negative")
+	v.Check(group.MaxChoices < 1, "max_choices", "max choices must be at least 1")
+	v.Check(group.MaxChoices < group.MinChoices, "max_choices", "max choices must not be less than min choices")
+	v.Check(group.Selection == SelectionSingle && group.MaxChoices != 1, "max_choices", "a single select group allows exactly one choice")
+	v.Check(group.Required && group.MinChoices < 1, "min_choices", "a required group needs at least one choice")
+	v.Check(len(group.Options) == 0, "options", "at least one option must be provided")
+	v.Check(len(group.Options) > 50, "options", "must not contain more than 50 options")
+	v.Check(len(group.Options) < group.MinChoices, "options", "there must be at least min_choices options")
+
+	for i, option := range group.Options {
+		ValidateOption(v, fmt.Sprintf("options.%d", i), *option)
+	}
+}
+
+func ValidateOption(v *validator.Validator, key string, option Option) {
+	v.Check(v.Empty(option.Name), key, "option name must be provided")
+	v.Check(len(option.Name) > 100, key, "option name must be less than 100 characters")
+	v.Check(option.PriceDeltaCent < -100000 || option.PriceDeltaCent > 100000, key, "price delta must be between -100000 and 100000")
+}
+
+// ValidateOptionSelection checks the options picked for a cart line against the
+// option groups of the menu item.
+func ValidateOptionSelection(v *validator.Validator, groups []*OptionGroup, optionIDs []int64) {
+	known := make(map[int64]*Option)
+	for _, group := range groups {
+		for _, option := range group.Options {
+			known[option.ID] = option
+		}
+	}
+
+	seen := make(map[int64]bool)
+	for _, id := range optionIDs {
+		option, ok := known[id]
+		v.Check(!ok, "options", fmt.Sprintf("option %d does not belong to this menu item", id))
+		v.Check(ok && !option.IsAvailable, "options", fmt.Sprintf("option %s is not available", optionName(option)))
+		v.Check(seen[id], "options", fmt.Sprintf("option %d is selected more than once", id))
+		seen[id] = true
+	}
+
+	for _, group := range groups {
+		picked := 0
+		for _, option := range group.Options {
+			if seen[option.ID] {
+				picked++
+			}
+		}
+
+		min := group.MinChoices
+		if group.Required && min < 1 {
+			min = 1
+		}
+
+		v.Check(picked < min, "options", fmt.Sprintf("%s needs at least %d choice(s)", group.Name, min))
+		v.Check(picked > group.MaxChoices, "options", fmt.Sprintf("%s allows at most %d choice(s)", group.Name, group.MaxChoices))
+	}
+}
+
+func optionName(option *Option) string {
+	if option == nil {
+		return ""
+	}
+	return option.Name
+}
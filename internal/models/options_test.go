@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOptionSelection(t *testing.T) {
+
+	groups := []*OptionGroup{
+		{ID: 1, Name: "size", Selection: SelectionSingle, MinChoices: 1, MaxChoices: 1, Required: true, Options: []*Option{
+			{ID: 10, Name: "small", IsAvailable: true},
+			{ID: 11, Name: "large", PriceDeltaCent: 200, IsAvailable: true},
+		}},
+		{ID: 2, Name: "extras", Selection: SelectionMulti, MaxChoices: 2, Options: []*Option{
+			{ID: 20, Name: "cheese", PriceDeltaCent: 150, IsAvailable: true},
+			{ID: 21, Name: "bacon", PriceDeltaCent: 250, IsAvailable: true},
+			{ID: 22, Name: "truffle", PriceDeltaCent: 900, IsAvailable: false},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		optionIDs []int64
+		valid     bool
+	}{
+		{"required only", []int64{11}, true},
+		{"with extras", []int64{10, 20, 21}, true},
+		{"missing required", []int64{20}, false},
+		{"two sizes", []int64{10, 11}, false},
+		{"unknown option", []int64{10, 99}, false},
+		{"unavailable option", []int64{10, 22}, false},
+		{"duplicate option", []int64{10, 20, 20}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateOptionSelection(v, groups, tt.optionIDs)
+			assert.Equal(t, tt.valid, v.Valid(), v.FieldErorrs)
+		})
+	}
+
+	v := validator.New()
+	ValidateOptionSelection(v, nil, nil)
+	assert.True(t, v.Valid())
+
+}
+
+func TestValidateOptionGroup(t *testing.T) {
+
+	group := OptionGroup{
+		Name:       "size",
+		Selection:  SelectionSingle,
+		MinChoices: 1,
+		MaxChoices: 1,
+		Required:   true,
+		Options:    []*Option{{Name: "small"}, {Name: "large", PriceDeltaCent: 200}},
+	}
+
+	v := validator.New()
+	ValidateOptionGroup(v, group)
+	assert.True(t, v.Valid())
+
+	group.MaxChoices = 2
+	v = validator.New()
+	ValidateOptionGroup(v, group)
+	assert.False(t, v.Valid())
+
+	group.Selection = SelectionMulti
+	group.MinChoices = 3
+	v = validator.New()
+	ValidateOptionGroup(v, group)
+	assert.False(t, v.Valid())
+
+}
@@ -0,0 +1,491 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	OrderStatusPendingPayment = "pending_payment"
+	OrderStatusPlaced         = "placed"
+	OrderStatusAccepted       = "accepted"
+	OrderStatusPreparing      = "preparing"
+	OrderStatusReady          = "ready"
+	OrderStatusCompleted      = "completed"
+	OrderStatusCancelled      = "cancelled"
+)
+
+// orderTransitions is the order state machine, every status maps to the statuses
+// it is allowed to move to. completed and cancelled are final.
+var orderTransitions = map[string][]string{
+	OrderStatusPendingPayment: {OrderStatusPlaced, OrderStatusCancelled},
+	OrderStatusPlaced:         {OrderStatusAccepted, OrderStatusCancelled},
+	OrderStatusAccepted:       {OrderStatusPreparing, OrderStatusCancelled},
+	OrderStatusPreparing:      {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:          {OrderStatusCompleted},
+	OrderStatusCompleted:      {},
+	OrderStatusCancelled:      {},
+}
+
+type Order struct {
+	ID           int64        `json:"id"`
+	UserID       *int64       `json:"user_id"`
+	RestaurantID int64        `json:"restaurant_id"`
+	Status       string       `json:"status"`
+	TotalCent    int64        `json:"total_cent"`
+	Items        []*OrderItem `json:"items,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+type OrderItem struct {
+	ID               int64    `json:"id"`
+	OrderID          int64    `json:"-"`
+	MenuID           *int64   `json:"menu_id"`
+	Name             string   `json:"name"`
+	Options          []string `json:"options,omitempty"`
+	Quantity         int      `json:"quantity"`
+	RefundedQuantity int      `json:"refunded_quantity"`
+	UnitPriceCent    int64    `json:"unit_price_cent"`
+}
+
+type OrderModel struct {
+	DB *sql.DB
+}
+
+func IsValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an order in the from status can move to the to status.
+func CanTransition(from, to string) bool {
+	next, ok := orderTransitions[from]
+	if !ok {
+		return false
+	}
+	return slices.Contains(next, to)
+}
+
+func ValidateTransition(from, to string) error {
+	if !IsValidOrderStatus(to) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, to)
+	}
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: cannot move order from %s to %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// CreateFromCart turns the user's cart into an order waiting for payment. The
+// price and the availability of every menu item are read inside the transaction,
+// so the order keeps the price the customer saw at checkout even if the menu
+// changes later. The cart is left alone until the payment goes through.
+func (m *OrderModel) CreateFromCart(userID int64) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, cartLines+" FOR SHARE OF m", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*CartItem
+	for rows.Next() {
+		var item CartItem
+
+		err := rows.Scan(&item.ID, &item.MenuID, &item.Name, &item.RestaurantID, &item.Quantity, &item.UnitPriceCent, &item.IsAvailable, pq.Array(&item.OptionIDs), &item.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	err = loadCartOptions(ctx, tx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{
+		UserID:       &userID,
+		RestaurantID: items[0].RestaurantID,
+		Status:       OrderStatusPendingPayment,
+		TotalCent:    CartTotal(items),
+	}
+
+	for _, item := range items {
+		if !item.IsAvailable {
+			return nil, fmt.Errorf("%w: %s", ErrMenuUnavailable, item.Name)
+		}
+		if item.RestaurantID != order.RestaurantID {
+			return nil, ErrMixedRestaurantCart
+		}
+	}
+
+	stmt := `INSERT INTO orders (user_id, restaurant_id, status, total_cent) VALUES($1, $2, $3, $4) RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, stmt, userID, order.RestaurantID, order.Status, order.TotalCent).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = `INSERT INTO order_items (order_id, menu_id, name, options, quantity, unit_price_cent) VALUES($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	for _, item := range items {
+		orderItem := &OrderItem{
+			OrderID:       order.ID,
+			MenuID:        &item.MenuID,
+			Name:          item.Name,
+			Options:       item.OptionNames(),
+			Quantity:      item.Quantity,
+			UnitPriceCent: item.UnitPriceCent,
+		}
+
+		args := []any{orderItem.OrderID, orderItem.MenuID, orderItem.Name, pq.Array(orderItem.Options), orderItem.Quantity, orderItem.UnitPriceCent}
+
+		err = tx.QueryRowContext(ctx, stmt, args...).Scan(&orderItem.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		order.Items = append(order.Items, orderItem)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+
+}
+
+func (m *OrderModel) Get(id int64) (*Order, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	stmt := `SELECT id, user_id, restaurant_id, status, total_cent, created_at, updated_at FROM orders WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var order Order
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&order.ID, &order.UserID, &order.RestaurantID, &order.Status, &order.TotalCent, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	order.Items, err = m.GetItems(order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+
+}
+
+func (m *OrderModel) GetItems(orderID int64) ([]*OrderItem, error) {
+	stmt := `SELECT id, order_id, menu_id, name, options, quantity, refunded_quantity, unit_price_cent FROM order_items WHERE order_id = $1 ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var items []*OrderItem
+	for rows.Next() {
+		var item OrderItem
+
+		err := rows.Scan(&item.ID, &item.OrderID, &item.MenuID, &item.Name, pq.Array(&item.Options), &item.Quantity, &item.RefundedQuantity, &item.UnitPriceCent)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+
+	return items, rows.Err()
+
+}
+
+func (m *OrderModel) GetAllForUser(userID int64) ([]*Order, error) {
+	stmt := `SELECT id, user_id, restaurant_id, status, total_cent, created_at, updated_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var orders []*Order
+	for rows.Next() {
+		var order Order
+
+		err := rows.Scan(&order.ID, &order.UserID, &order.RestaurantID, &order.Status, &order.TotalCent, &order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, &order)
+	}
+
+	return orders, rows.Err()
+
+}
+
+// UpdateStatus moves the order to a new status. The update only happens if the
+// order is still in the status it was read with, otherwise ErrConflictEdit is
+// returned so two concurrent transitions can't both succeed.
+func (m *OrderModel) UpdateStatus(order *Order, status string) error {
+	err := ValidateTransition(order.Status, status)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3 RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, stmt, status, order.ID, order.Status).Scan(&order.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrConflictEdit
+		default:
+			return err
+		}
+	}
+
+	order.Status = status
+
+	return nil
+
+}
+
+type OrderFilters struct {
+	Status string
+	From   *time.Time
+	To     *time.Time
+	Filters
+}
+
+func (m *OrderModel) GetAllForRestaurant(restaurantID int64, of OrderFilters) ([]*Order, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), id, user_id, restaurant_id, status, total_cent, created_at, updated_at FROM orders
+	WHERE restaurant_id = $1
+	AND (status = $2 OR $2 = '')
+	AND (created_at >= $3::timestamptz OR $3::timestamptz IS NULL)
+	AND (created_at < $4::timestamptz OR $4::timestamptz IS NULL)
+	ORDER BY %s %s, id ASC LIMIT %d OFFSET %d`, of.sortColumn(), of.sortDirection(), of.Limit(), of.Offset())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, restaurantID, of.Status, of.From, of.To)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	var totalRecords int
+	var orders []*Order
+	for rows.Next() {
+		var order Order
+
+		err := rows.Scan(&totalRecords, &order.ID, &order.UserID, &order.RestaurantID, &order.Status, &order.TotalCent, &order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		orders = append(orders, &order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, of.Page, of.PageSize)
+
+	return orders, metadata, nil
+
+}
+
+// GetKitchenQueue returns the orders the kitchen is working on, oldest first,
+// together with their items.
+func (m *OrderModel) GetKitchenQueue(restaurantID int64) ([]*Order, error) {
+	stmt := `SELECT id, user_id, restaurant_id, status, total_cent, created_at, updated_at FROM orders
+	WHERE restaurant_id = $1 AND status = ANY($2)
+	ORDER BY created_at ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	statuses := []string{OrderStatusAccepted, OrderStatusPreparing, OrderStatusReady}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, restaurantID, pq.Array(statuses))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var orders []*Order
+	byID := make(map[int64]*Order)
+	var ids []int64
+	for rows.Next() {
+		var order Order
+
+		err := rows.Scan(&order.ID, &order.UserID, &order.RestaurantID, &order.Status, &order.TotalCent, &order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, &order)
+		byID[order.ID] = &order
+		ids = append(ids, order.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return orders, nil
+	}
+
+	stmt = `SELECT id, order_id, menu_id, name, options, quantity, refunded_quantity, unit_price_cent FROM order_items WHERE order_id = ANY($1) ORDER BY id ASC`
+
+	itemRows, err := m.DB.QueryContext(ctx, stmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item OrderItem
+
+		err := itemRows.Scan(&item.ID, &item.OrderID, &item.MenuID, &item.Name, pq.Array(&item.Options), &item.Quantity, &item.RefundedQuantity, &item.UnitPriceCent)
+		if err != nil {
+			return nil, err
+		}
+
+		order := byID[item.OrderID]
+		order.Items = append(order.Items, &item)
+	}
+
+	return orders, itemRows.Err()
+
+}
+
+// GetIDsWithStatus returns the orders of the restaurant in the status, among ids
+// when any are given.
+func (m *OrderModel) GetIDsWithStatus(restaurantID int64, status string, ids []int64) ([]int64, error) {
+	stmt := `SELECT id FROM orders
+	WHERE restaurant_id = $1 AND status = $2 AND (cardinality($3::bigint[]) = 0 OR id = ANY($3))
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, restaurantID, status, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	matching := []int64{}
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		matching = append(matching, id)
+	}
+
+	return matching, rows.Err()
+
+}
+
+// BulkUpdateStatus moves every order of the restaurant that is in the from
+// status to the to status. When ids is not empty only those orders are touched.
+// It returns the ids of the orders that were updated.
+func (m *OrderModel) BulkUpdateStatus(restaurantID int64, from, to string, ids []int64) ([]int64, error) {
+	err := ValidateTransition(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `UPDATE orders SET status = $1, updated_at = NOW()
+	WHERE restaurant_id = $2 AND status = $3 AND (cardinality($4::bigint[]) = 0 OR id = ANY($4))
+	RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, to, restaurantID, from, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	updated := []int64{}
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		updated = append(updated, id)
+	}
+
+	return updated, rows.Err()
+
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{OrderStatusPendingPayment, OrderStatusPlaced, true},
+		{OrderStatusPendingPayment, OrderStatusAccepted, false},
+		{OrderStatusPlaced, OrderStatusAccepted, true},
+		{OrderStatusPlaced, OrderStatusCancelled, true},
+		{OrderStatusPlaced, OrderStatusReady, false},
+		{OrderStatusAccepted, OrderStatusPreparing, true},
+		{OrderStatusAccepted, OrderStatusPlaced, false},
+		{OrderStatusPreparing, OrderStatusReady, true},
+		{OrderStatusReady, OrderStatusCompleted, true},
+		{OrderStatusReady, OrderStatusCancelled, false},
+		{OrderStatusCompleted, OrderStatusCancelled, false},
+		{OrderStatusCancelled, OrderStatusPlaced, false},
+		{"unknown", OrderStatusPlaced, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CanTransition(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+
+}
+
+func TestValidateTransition(t *testing.T) {
+
+	assert.NoError(t, ValidateTransition(OrderStatusPlaced, OrderStatusAccepted))
+
+	err := ValidateTransition(OrderStatusCompleted, OrderStatusPlaced)
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+
+	err = ValidateTransition(OrderStatusPlaced, "shipped")
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+
+}
+
+func TestCartTotal(t *testing.T) {
+
+	items := []*CartItem{
+		{UnitPriceCent: 450, Quantity: 2},
+		{UnitPriceCent: 1200, Quantity: 1},
+	}
+
+	assert.Equal(t, int64(2100), CartTotal(items))
+	assert.Equal(t, int64(0), CartTotal(nil))
+
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	PaymentStatusPending    = "pending"
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusFailed     = "failed"
+	PaymentStatusVoided     = "voided"
+)
+
+// Payment is one payment attempt for an order. Failed attempts are kept so the
+// payments can be reconciled with the provider later.
+type Payment struct {
+	ID           int64     `json:"id"`
+	OrderID      int64     `json:"order_id"`
+	Provider     string    `json:"provider"`
+	ProviderRef  *string   `json:"provider_ref"`
+	AmountCent   int64     `json:"amount_cent"`
+	CapturedCent int64     `json:"captured_cent"`
+	RefundedCent int64     `json:"refunded_cent"`
+	Status       string    `json:"status"`
+	Error        *string   `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type PaymentModel struct {
+	DB *sql.DB
+}
+
+func (m *PaymentModel) Insert(payment *Payment) error {
+	stmt := `INSERT INTO payments (order_id, provider, provider_ref, amount_cent, status) VALUES($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{payment.OrderID, payment.Provider, payment.ProviderRef, payment.AmountCent, payment.Status}
+
+	return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+
+}
+
+func (m *PaymentModel) Update(payment *Payment) error {
+	stmt := `UPDATE payments SET provider_ref = $1, captured_cent = $2, status = $3, error = $4, updated_at = NOW() WHERE id = $5 RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{payment.ProviderRef, payment.CapturedCent, payment.Status, payment.Error, payment.ID}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&payment.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// GetSuccessfulForOrder returns the authorized or captured payment of the order.
+func (m *PaymentModel) GetSuccessfulForOrder(orderID int64) (*Payment, error) {
+	stmt := `SELECT id, order_id, provider, provider_ref, amount_cent, captured_cent, refunded_cent, status, error, created_at, updated_at FROM payments
+	WHERE order_id = $1 AND status IN ('authorized', 'captured')
+	ORDER BY id DESC LIMIT 1`
+
+	return m.getOne(stmt, orderID)
+
+}
+
+func (m *PaymentModel) GetByProviderRef(provider, reference string) (*Payment, error) {
+	stmt := `SELECT id, order_id, provider, provider_ref, amount_cent, captured_cent, refunded_cent, status, error, created_at, updated_at FROM payments
+	WHERE provider = $1 AND provider_ref = $2`
+
+	return m.getOne(stmt, provider, reference)
+
+}
+
+func (m *PaymentModel) GetAllForOrder(orderID int64) ([]*Payment, error) {
+	stmt := `SELECT id, order_id, provider, provider_ref, amount_cent, captured_cent, refunded_cent, status, error, created_at, updated_at FROM payments
+	WHERE order_id = $1 ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var payments []*Payment
+	for rows.Next() {
+		var payment Payment
+
+		err := rows.Scan(&payment.ID, &payment.OrderID, &payment.Provider, &payment.ProviderRef, &payment.AmountCent, &payment.CapturedCent, &payment.RefundedCent, &payment.Status, &payment.Error, &payment.CreatedAt, &payment.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, &payment)
+	}
+
+	return payments, rows.Err()
+
+}
+
+func (m *PaymentModel) getOne(stmt string, args ...any) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var payment Payment
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&payment.ID, &payment.OrderID, &payment.Provider, &payment.ProviderRef, &payment.AmountCent, &payment.CapturedCent, &payment.RefundedCent, &payment.Status, &payment.Error, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &payment, nil
+
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"slices"
 	"time"
 
@@ -19,11 +21,17 @@ type PermissionModel struct {
 	DB *sql.DB
 }
 
+// GetForAllUser returns the permissions granted to the user directly together
+// with the ones of the user's roles.
 func (m *PermissionModel) GetForAllUser(userID int64) (Permissions, error) {
 	stmt := `SELECT p.code FROM Permissions AS p
 	INNER JOIN users_permissions as up on up.Permission_id = p.id
-	INNER JOIN users AS u on up.user_id = u.id
-	WHERE up.user_id = $1`
+	WHERE up.user_id = $1
+	UNION
+	SELECT p.code FROM permissions AS p
+	INNER JOIN roles_permissions AS rp ON rp.permission_id = p.id
+	INNER JOIN users_roles AS ur ON ur.role_id = rp.role_id
+	WHERE ur.user_id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -54,7 +62,8 @@ func (m *PermissionModel) GetForAllUser(userID int64) (Permissions, error) {
 
 func (m *PermissionModel) AddForUser(userID int64, codes ...string) error {
 	stmt := `INSERT INTO users_permissions
-	SELECT $1, permissions.id from Permissions WHERE permissions.code = ANY($2)`
+	SELECT $1, permissions.id from Permissions WHERE permissions.code = ANY($2)
+	ON CONFLICT DO NOTHING`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -63,3 +72,66 @@ func (m *PermissionModel) AddForUser(userID int64, codes ...string) error {
 	return err
 
 }
+
+// RemoveForUser takes permissions granted directly away from the user. A
+// permission that comes with one of the user's roles can't be taken away like
+// that, the error wraps ErrPermissionFromRole and names the role then.
+func (m *PermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	stmt := `SELECT p.code, r.name FROM permissions AS p
+	INNER JOIN roles_permissions AS rp ON rp.permission_id = p.id
+	INNER JOIN roles AS r ON r.id = rp.role_id
+	INNER JOIN users_roles AS ur ON ur.role_id = r.id
+	WHERE ur.user_id = $1 AND p.code = ANY($2)
+	ORDER BY p.code, r.name LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var code, role string
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, pq.Array(codes)).Scan(&code, &role)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%s comes with the %s role, %w", code, role, ErrPermissionFromRole)
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	stmt = `DELETE FROM users_permissions
+	WHERE user_id = $1 AND permission_id IN (SELECT id FROM permissions WHERE code = ANY($2))`
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, pq.Array(codes))
+	return err
+
+}
+
+// GetAll returns every permission code that can be granted.
+func (m *PermissionModel) GetAll() (Permissions, error) {
+	stmt := `SELECT code FROM permissions ORDER BY code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	permissions := Permissions{}
+
+	for rows.Next() {
+		var permission string
+
+		err = rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+// reorder rewrites the positions of the rows of table whose column equals
+// scopeID to the order of ids in one transaction. The rows are locked first,
+// so ids has to list exactly the rows there are, otherwise ErrInvalidOrder is
+// returned and nothing changes.
+func reorder(db *sql.DB, table, column string, scopeID int64, ids []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT id FROM %s WHERE %s = $1 FOR UPDATE`, table, column), scopeID)
+	if err != nil {
+		return err
+	}
+
+	var current []int64
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		current = append(current, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if !samePositions(current, ids) {
+		return ErrInvalidOrder
+	}
+
+	stmt := fmt.Sprintf(`UPDATE %s t SET position = o.position FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position) WHERE t.id = o.id`, table)
+
+	_, err = tx.ExecContext(ctx, stmt, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// samePositions reports whether ids lists every id of current exactly once.
+func samePositions(current, ids []int64) bool {
+	if len(current) != len(ids) {
+		return false
+	}
+
+	current = slices.Clone(current)
+	ids = slices.Clone(ids)
+	slices.Sort(current)
+	slices.Sort(ids)
+
+	return slices.Equal(current, ids)
+}
+
+func ValidatePositions(v *validator.Validator, ids []int64) {
+	v.Check(len(ids) == 0, "ids", "ids must contain at least one id")
+	v.Check(len(ids) > 500, "ids", "ids must not contain more than 500 ids")
+	v.Check(!validator.Unique(ids), "ids", "ids must not contain duplicate values")
+}
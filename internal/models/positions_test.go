@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamePositions(t *testing.T) {
+
+	current := []int64{4, 7, 9}
+
+	assert.True(t, samePositions(current, []int64{9, 4, 7}))
+	assert.False(t, samePositions(current, []int64{9, 4}))
+	assert.False(t, samePositions(current, []int64{9, 4, 8}))
+	assert.False(t, samePositions(current, []int64{9, 4, 7, 12}))
+
+	// the order of the caller is left alone
+	ids := []int64{9, 4, 7}
+	samePositions(current, ids)
+	assert.Equal(t, []int64{9, 4, 7}, ids)
+	assert.Equal(t, []int64{4, 7, 9}, current)
+
+}
+
+func TestValidatePositions(t *testing.T) {
+
+	v := validator.New()
+	ValidatePositions(v, []int64{3, 1, 2})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidatePositions(v, nil)
+	assert.Contains(t, v.FieldErorrs, "ids")
+
+	v = validator.New()
+	ValidatePositions(v, []int64{3, 1, 3})
+	assert.Equal(t, "ids must not contain duplicate values", v.FieldErorrs["ids"])
+
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// TokenPair is what a login or a refresh hands out: a short lived access token
+// for the Authorization header and a refresh token to get the next pair.
+type TokenPair struct {
+	Access  *Token
+	Refresh *Token
+	Family  string
+}
+
+// NewPair starts a new token family for the user, every refresh token rotated
+// out of it later stays in the same family.
+func (m *TokenModel) NewPair(userID int64, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	familyBytes := make([]byte, 16)
+
+	_, err := rand.Read(familyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pair, err := insertPair(ctx, tx, userID, hex.EncodeToString(familyBytes), accessTTL, refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, tx.Commit()
+}
+
+// Rotate trades a refresh token for a new pair of the same family. A refresh
+// token can only be used once, presenting it again means it was stolen, so the
+// whole family is revoked and ErrTokenReused is returned with the owner's id.
+func (m *TokenModel) Rotate(plainRefresh string, accessTTL, refreshTTL time.Duration) (*TokenPair, int64, error) {
+	hash := sha256.Sum256([]byte(plainRefresh))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT user_id, family, expiry, used_at FROM tokens WHERE hash = $1 AND scope = $2 FOR UPDATE`
+
+	var userID int64
+	var family string
+	var expiry time.Time
+	var usedAt *time.Time
+
+	err = tx.QueryRowContext(ctx, stmt, hash[:], RefreshScope).Scan(&userID, &family, &expiry, &usedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, 0, ErrRecordNotFound
+		default:
+			return nil, 0, err
+		}
+	}
+
+	if usedAt != nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE family = $1`, family)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return nil, userID, ErrTokenReused
+	}
+
+	if !expiry.After(time.Now()) {
+		return nil, 0, ErrRecordNotFound
+	}
+
+	// the used token is kept until it expires to recognize a replay
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET used_at = NOW() WHERE hash = $1`, hash[:])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pair, err := insertPair(ctx, tx, userID, family, accessTTL, refreshTTL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return pair, userID, tx.Commit()
+}
+
+// RevokeFamily deletes the token and every other token of its family.
+func (m *TokenModel) RevokeFamily(plainToken string) error {
+	hash := sha256.Sum256([]byte(plainToken))
+
+	stmt := `DELETE FROM tokens WHERE hash = $1
+	OR family = (SELECT family FROM tokens WHERE hash = $1 AND family IS NOT NULL)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, hash[:])
+	return err
+}
+
+// DeleteFamily deletes every token of a token family, used to log out a session
+// whose access token isn't stored like the signed ones.
+func (m *TokenModel) DeleteFamily(family string) error {
+	stmt := `DELETE FROM tokens WHERE family = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, family)
+	return err
+}
+
+func insertPair(ctx context.Context, tx *sql.Tx, userID int64, family string, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	access, err := GenerateToken(accessTTL, userID, AuthenticationScope)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := GenerateToken(refreshTTL, userID, RefreshScope)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO tokens (hash, user_id, expiry, scope, family) VALUES($1, $2, $3, $4, $5)`
+
+	for _, token := range []*Token{access, refresh} {
+		_, err = tx.ExecContext(ctx, stmt, token.Hash, token.UserID, token.Expiry, token.Scope, family)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &TokenPair{Access: access, Refresh: refresh, Family: family}, nil
+}
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+type Refund struct {
+	ID          int64         `json:"id"`
+	OrderID     int64         `json:"order_id"`
+	PaymentID   int64         `json:"payment_id"`
+	AmountCent  int64         `json:"amount_cent"`
+	Reason      string        `json:"reason"`
+	Status      string        `json:"status"`
+	ProviderRef *string       `json:"provider_ref"`
+	Error       *string       `json:"error,omitempty"`
+	CreatedBy   *int64        `json:"created_by"`
+	Items       []*RefundItem `json:"items"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type RefundItem struct {
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+	AmountCent  int64 `json:"amount_cent"`
+}
+
+type RefundModel struct {
+	DB *sql.DB
+}
+
+// RemainingRefundItems returns a refund line for everything of the order that
+// hasn't been refunded yet, it is used for whole order refunds.
+func RemainingRefundItems(order *Order) []*RefundItem {
+	var items []*RefundItem
+	for _, item := range order.Items {
+		remaining := item.Quantity - item.RefundedQuantity
+		if remaining > 0 {
+			items = append(items, &RefundItem{OrderItemID: item.ID, Quantity: remaining})
+		}
+	}
+	return items
+}
+
+// ValidateRefundItems checks the refund lines against the order and fills in the
+// amount of every line from the price the customer paid.
+func ValidateRefundItems(v *validator.Validator, order *Order, items []*RefundItem) {
+	v.Check(len(items) == 0, "items", "there is nothing left to refund on this order")
+
+	seen := make(map[int64]bool)
+	for _, refundItem := range items {
+		key := fmt.Sprintf("items.%d", refundItem.OrderItemID)
+
+		if seen[refundItem.OrderItemID] {
+			v.AddError(key, "order item can only be listed once")
+			continue
+		}
+		seen[refundItem.OrderItemID] = true
+
+		var orderItem *OrderItem
+		for _, item := range order.Items {
+			if item.ID == refundItem.OrderItemID {
+				orderItem = item
+				break
+			}
+		}
+
+		if orderItem == nil {
+			v.AddError(key, "order item doesn't belong to this order")
+			continue
+		}
+
+		v.Check(refundItem.Quantity < 1, key, "quantity must be at least 1")
+		v.Check(refundItem.Quantity > orderItem.Quantity-orderItem.RefundedQuantity, key, "quantity is more than what is left to refund")
+
+		refundItem.AmountCent = orderItem.UnitPriceCent * int64(refundItem.Quantity)
+	}
+}
+
+func RefundTotal(items []*RefundItem) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.AmountCent
+	}
+	return total
+}
+
+// Begin records a pending refund and reserves it: the refunded quantities, the
+// order total and the refunded amount of the payment are updated in the same
+// transaction, so two concurrent refunds can't give back more than was paid.
+// Once the provider answered, the refund has to be finished with Complete or Fail.
+func (m *RefundModel) Begin(refund *Refund) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE order_items SET refunded_quantity = refunded_quantity + $1
+	WHERE id = $2 AND order_id = $3 AND refunded_quantity + $1 <= quantity`
+
+	for _, item := range refund.Items {
+		result, err := tx.ExecContext(ctx, stmt, item.Quantity, item.OrderItemID, refund.OrderID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrInvalidRefund
+		}
+	}
+
+	stmt = `UPDATE payments SET refunded_cent = refunded_cent + $1, updated_at = NOW()
+	WHERE id = $2 AND refunded_cent + $1 <= captured_cent`
+
+	result, err := tx.ExecContext(ctx, stmt, refund.AmountCent, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrInvalidRefund
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET total_cent = total_cent - $1, updated_at = NOW() WHERE id = $2`, refund.AmountCent, refund.OrderID)
+	if err != nil {
+		return err
+	}
+
+	refund.Status = RefundStatusPending
+
+	stmt = `INSERT INTO refunds (order_id, payment_id, amount_cent, reason, status, created_by) VALUES($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	args := []any{refund.OrderID, refund.PaymentID, refund.AmountCent, refund.Reason, refund.Status, refund.CreatedBy}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO refund_items (refund_id, order_item_id, quantity, amount_cent) VALUES($1, $2, $3, $4)`
+
+	for _, item := range refund.Items {
+		_, err = tx.ExecContext(ctx, stmt, refund.ID, item.OrderItemID, item.Quantity, item.AmountCent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+
+}
+
+func (m *RefundModel) Complete(refund *Refund, providerRef string) error {
+	stmt := `UPDATE refunds SET status = $1, provider_ref = $2 WHERE id = $3 AND status = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, RefundStatusSucceeded, providerRef, refund.ID, RefundStatusPending)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	refund.Status = RefundStatusSucceeded
+	refund.ProviderRef = &providerRef
+
+	return nil
+
+}
+
+// Fail marks the refund as failed and gives back everything Begin reserved.
+func (m *RefundModel) Fail(refund *Refund, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE refunds SET status = $1, error = $2 WHERE id = $3 AND status = $4`, RefundStatusFailed, reason, refund.ID, RefundStatusPending)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	for _, item := range refund.Items {
+		_, err = tx.ExecContext(ctx, `UPDATE order_items SET refunded_quantity = refunded_quantity - $1 WHERE id = $2`, item.Quantity, item.OrderItemID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE payments SET refunded_cent = refunded_cent - $1, updated_at = NOW() WHERE id = $2`, refund.AmountCent, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET total_cent = total_cent + $1, updated_at = NOW() WHERE id = $2`, refund.AmountCent, refund.OrderID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	refund.Status = RefundStatusFailed
+	refund.Error = &reason
+
+	return nil
+
+}
+
+func (m *RefundModel) GetAllForOrder(orderID int64) ([]*Refund, error) {
+	stmt := `SELECT id, order_id, payment_id, amount_cent, reason, status, provider_ref, error, created_by, created_at FROM refunds
+	WHERE order_id = $1 ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	refunds := []*Refund{}
+	byID := make(map[int64]*Refund)
+	var ids []int64
+	for rows.Next() {
+		var refund Refund
+
+		err := rows.Scan(&refund.ID, &refund.OrderID, &refund.PaymentID, &refund.AmountCent, &refund.Reason, &refund.Status, &refund.ProviderRef, &refund.Error, &refund.CreatedBy, &refund.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		refunds = append(refunds, &refund)
+		byID[refund.ID] = &refund
+		ids = append(ids, refund.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return refunds, nil
+	}
+
+	itemRows, err := m.DB.QueryContext(ctx, `SELECT refund_id, order_item_id, quantity, amount_cent FROM refund_items WHERE refund_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var refundID int64
+		var item RefundItem
+
+		err := itemRows.Scan(&refundID, &item.OrderItemID, &item.Quantity, &item.AmountCent)
+		if err != nil {
+			return nil, err
+		}
+
+		refund := byID[refundID]
+		refund.Items = append(refund.Items, &item)
+	}
+
+	return refunds, itemRows.Err()
+
+}
+
+// IsFullyRefunded reports whether every item of the order has been refunded.
+func (m *RefundModel) IsFullyRefunded(orderID int64) (bool, error) {
+	stmt := `SELECT NOT EXISTS(SELECT FROM order_items WHERE order_id = $1 AND refunded_quantity < quantity)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var ok bool
+	err := m.DB.QueryRowContext(ctx, stmt, orderID).Scan(&ok)
+	return ok, err
+
+}
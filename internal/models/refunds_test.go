@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRefundItems(t *testing.T) {
+
+	order := &Order{
+		Items: []*OrderItem{
+			{ID: 1, Quantity: 2, RefundedQuantity: 0, UnitPriceCent: 500},
+			{ID: 2, Quantity: 1, RefundedQuantity: 1, UnitPriceCent: 900},
+		},
+	}
+
+	items := []*RefundItem{{OrderItemID: 1, Quantity: 2}}
+	v := validator.New()
+	ValidateRefundItems(v, order, items)
+	assert.True(t, v.Valid())
+	assert.Equal(t, int64(1000), RefundTotal(items))
+
+	v = validator.New()
+	ValidateRefundItems(v, order, []*RefundItem{{OrderItemID: 2, Quantity: 1}})
+	assert.False(t, v.Valid())
+
+	v = validator.New()
+	ValidateRefundItems(v, order, []*RefundItem{{OrderItemID: 3, Quantity: 1}})
+	assert.False(t, v.Valid())
+
+	remaining := RemainingRefundItems(order)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, int64(1), remaining[0].OrderItemID)
+	assert.Equal(t, 2, remaining[0].Quantity)
+
+}
@@ -0,0 +1,404 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+const (
+	ReservationStatusBooked    = "booked"
+	ReservationStatusCancelled = "cancelled"
+
+	// every reservation holds its table for ReservationDuration, slots start
+	// every ReservationSlotInterval between the first and the last slot of the day.
+	ReservationDuration     = 2 * time.Hour
+	ReservationSlotInterval = 30 * time.Minute
+	reservationFirstSlot    = 10 * time.Hour
+	reservationLastSlot     = 21 * time.Hour
+)
+
+type Table struct {
+	ID           int64     `json:"id"`
+	RestaurantID int64     `json:"restaurant_id"`
+	Name         string    `json:"name"`
+	Seats        int       `json:"seats"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Reservation struct {
+	ID           int64     `json:"id"`
+	RestaurantID int64     `json:"restaurant_id"`
+	TableID      int64     `json:"table_id"`
+	UserID       *int64    `json:"user_id"`
+	PartySize    int       `json:"party_size"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type Slot struct {
+	StartsAt   time.Time `json:"starts_at"`
+	FreeTables int       `json:"free_tables"`
+}
+
+type TableModel struct {
+	DB *sql.DB
+}
+
+type ReservationModel struct {
+	DB *sql.DB
+}
+
+func (m *TableModel) Insert(table *Table) error {
+	stmt := `INSERT INTO restaurant_tables (restaurant_id, name, seats) VALUES($1, $2, $3) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, table.RestaurantID, table.Name, table.Seats).Scan(&table.ID, &table.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "restaurant_tables_name_key"):
+			return ErrDuplicateTableName
+		default:
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+func (m *TableModel) GetAllForRestaurant(restaurantID int64) ([]*Table, error) {
+	stmt := `SELECT id, restaurant_id, name, seats, created_at FROM restaurant_tables WHERE restaurant_id = $1 ORDER BY seats ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tables []*Table
+	for rows.Next() {
+		var table Table
+
+		err := rows.Scan(&table.ID, &table.RestaurantID, &table.Name, &table.Seats, &table.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, &table)
+	}
+
+	return tables, rows.Err()
+
+}
+
+// Delete refuses to remove a table that still has upcoming bookings. Its past
+// and cancelled reservations are removed with it.
+func (m *TableModel) Delete(restaurantID, id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the lock makes new bookings of the table wait until it is gone
+	var tableID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM restaurant_tables WHERE id = $1 AND restaurant_id = $2 FOR UPDATE`, id, restaurantID).Scan(&tableID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	var upcoming bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT FROM reservations WHERE table_id = $1 AND status = $2 AND ends_at > NOW())`, id, ReservationStatusBooked).Scan(&upcoming)
+	if err != nil {
+		return err
+	}
+
+	if upcoming {
+		return ErrTableHasReservations
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM reservations WHERE table_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM restaurant_tables WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+
+}
+
+// Book puts the reservation on the smallest free table that fits the party.
+// Double booking is prevented by the reservations_no_overlap exclusion
+// constraint, when it fires the next table is tried.
+func (m *ReservationModel) Book(reservation *Reservation) error {
+	stmt := `INSERT INTO reservations (restaurant_id, table_id, user_id, party_size, starts_at, ends_at, status)
+	VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`
+
+	reservation.EndsAt = reservation.StartsAt.Add(ReservationDuration)
+	reservation.Status = ReservationStatusBooked
+
+	return m.tryTables(reservation, func(ctx context.Context, tableID int64) error {
+		args := []any{reservation.RestaurantID, tableID, reservation.UserID, reservation.PartySize, reservation.StartsAt, reservation.EndsAt, reservation.Status}
+		return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+	})
+
+}
+
+// Modify moves a booked reservation to a new time or party size, the table may
+// change if the current one doesn't fit anymore.
+func (m *ReservationModel) Modify(reservation *Reservation) error {
+	stmt := `UPDATE reservations SET table_id = $1, party_size = $2, starts_at = $3, ends_at = $4, updated_at = NOW()
+	WHERE id = $5 AND status = $6 RETURNING updated_at`
+
+	reservation.EndsAt = reservation.StartsAt.Add(ReservationDuration)
+
+	return m.tryTables(reservation, func(ctx context.Context, tableID int64) error {
+		args := []any{tableID, reservation.PartySize, reservation.StartsAt, reservation.EndsAt, reservation.ID, ReservationStatusBooked}
+		err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&reservation.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	})
+
+}
+
+func (m *ReservationModel) tryTables(reservation *Reservation, write func(ctx context.Context, tableID int64) error) error {
+	stmt := `SELECT id FROM restaurant_tables WHERE restaurant_id = $1 AND seats >= $2 ORDER BY seats ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, reservation.RestaurantID, reservation.PartySize)
+	if err != nil {
+		return err
+	}
+
+	var tableIDs []int64
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		tableIDs = append(tableIDs, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, tableID := range tableIDs {
+		err = write(ctx, tableID)
+		if err == nil {
+			reservation.TableID = tableID
+			return nil
+		}
+
+		if !strings.Contains(err.Error(), "reservations_no_overlap") {
+			return err
+		}
+	}
+
+	return ErrNoTableAvailable
+
+}
+
+func (m *ReservationModel) Get(id int64) (*Reservation, error) {
+	stmt := `SELECT id, restaurant_id, table_id, user_id, party_size, starts_at, ends_at, status, created_at, updated_at FROM reservations WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservation Reservation
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&reservation.ID, &reservation.RestaurantID, &reservation.TableID, &reservation.UserID, &reservation.PartySize, &reservation.StartsAt, &reservation.EndsAt, &reservation.Status, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &reservation, nil
+
+}
+
+func (m *ReservationModel) Cancel(reservation *Reservation) error {
+	stmt := `UPDATE reservations SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3 RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, ReservationStatusCancelled, reservation.ID, ReservationStatusBooked).Scan(&reservation.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	reservation.Status = ReservationStatusCancelled
+
+	return nil
+
+}
+
+func (m *ReservationModel) GetAllForUser(userID int64) ([]*Reservation, error) {
+	stmt := `SELECT id, restaurant_id, table_id, user_id, party_size, starts_at, ends_at, status, created_at, updated_at FROM reservations
+	WHERE user_id = $1 ORDER BY starts_at DESC`
+
+	return m.getAll(stmt, userID)
+
+}
+
+// GetAllForRestaurant returns the reservations of the restaurant that start in
+// the [from, to) window. Cancelled reservations are only included when asked for.
+func (m *ReservationModel) GetAllForRestaurant(restaurantID int64, from, to time.Time, withCancelled bool) ([]*Reservation, error) {
+	stmt := `SELECT id, restaurant_id, table_id, user_id, party_size, starts_at, ends_at, status, created_at, updated_at FROM reservations
+	WHERE restaurant_id = $1 AND starts_at < $3 AND ends_at > $2 AND (status = 'booked' OR $4)
+	ORDER BY starts_at ASC, table_id ASC`
+
+	return m.getAll(stmt, restaurantID, from, to, withCancelled)
+
+}
+
+func (m *ReservationModel) getAll(stmt string, args ...any) ([]*Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var reservations []*Reservation
+	for rows.Next() {
+		var reservation Reservation
+
+		err := rows.Scan(&reservation.ID, &reservation.RestaurantID, &reservation.TableID, &reservation.UserID, &reservation.PartySize, &reservation.StartsAt, &reservation.EndsAt, &reservation.Status, &reservation.CreatedAt, &reservation.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		reservations = append(reservations, &reservation)
+	}
+
+	return reservations, rows.Err()
+
+}
+
+// ReservationSlots returns the start of every bookable slot of the day in the
+// restaurant's timezone. With opening hours only the slots the restaurant is
+// open at are kept, without them the default window of the day is used.
+func ReservationSlots(day time.Time, s *Schedule) []time.Time {
+	loc := s.Location()
+
+	var slots []time.Time
+	for offset := time.Duration(0); offset < 24*time.Hour; offset += ReservationSlotInterval {
+		// built from the wall clock so daylight saving shifts don't move the slots
+		slot := time.Date(day.Year(), day.Month(), day.Day(), 0, int(offset/time.Minute), 0, 0, loc)
+
+		if len(s.Hours) == 0 && (offset < reservationFirstSlot || offset > reservationLastSlot) {
+			continue
+		}
+
+		if s.IsOpenAt(slot) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// AvailableSlots counts for every slot the tables that fit the party and are
+// not booked during the reservation that would start in that slot.
+func AvailableSlots(slots []time.Time, tables []*Table, booked []*Reservation, partySize int) []Slot {
+	available := []Slot{}
+
+	for _, start := range slots {
+		end := start.Add(ReservationDuration)
+
+		free := 0
+		for _, table := range tables {
+			if table.Seats < partySize {
+				continue
+			}
+
+			taken := false
+			for _, reservation := range booked {
+				if reservation.TableID == table.ID && reservation.Status == ReservationStatusBooked &&
+					reservation.StartsAt.Before(end) && reservation.EndsAt.After(start) {
+					taken = true
+					break
+				}
+			}
+
+			if !taken {
+				free++
+			}
+		}
+
+		if free > 0 {
+			available = append(available, Slot{StartsAt: start, FreeTables: free})
+		}
+	}
+
+	return available
+}
+
+func IsReservationSlot(t time.Time, s *Schedule) bool {
+	for _, slot := range ReservationSlots(t.In(s.Location()), s) {
+		if slot.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateTable(v *validator.Validator, table Table) {
+	v.Check(v.Empty(table.Name), "name", "table name must be provided")
+	v.Check(len(table.Name) > 50, "name", "table name must be less than 50 characters")
+	v.Check(table.Seats < 1 || table.Seats > 50, "seats", "seats must be between 1 and 50")
+}
+
+func ValidateReservation(v *validator.Validator, reservation Reservation, s *Schedule) {
+	v.Check(reservation.PartySize < 1 || reservation.PartySize > 50, "party_size", "party size must be between 1 and 50")
+	v.Check(reservation.StartsAt.Before(time.Now()), "starts_at", "reservation must be in the future")
+	v.Check(!IsReservationSlot(reservation.StartsAt, s), "starts_at", "starts_at must be one of the slots returned by the availability endpoint")
+}
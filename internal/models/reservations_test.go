@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservationSlots(t *testing.T) {
+
+	day := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+
+	s := &Schedule{Timezone: "UTC"}
+
+	slots := ReservationSlots(day, s)
+
+	assert.Equal(t, time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC), slots[0])
+	assert.Equal(t, time.Date(2026, 10, 20, 21, 0, 0, 0, time.UTC), slots[len(slots)-1])
+	assert.True(t, IsReservationSlot(time.Date(2026, 10, 20, 18, 30, 0, 0, time.UTC), s))
+	assert.False(t, IsReservationSlot(time.Date(2026, 10, 20, 18, 15, 0, 0, time.UTC), s))
+	assert.False(t, IsReservationSlot(time.Date(2026, 10, 20, 23, 0, 0, 0, time.UTC), s))
+
+	// 2026-10-20 is a Tuesday, the restaurant opens from noon to 14:00 in Berlin
+	s = &Schedule{
+		Timezone: "Europe/Berlin",
+		Hours:    []*OpeningHours{{Weekday: 2, OpensAt: "12:00", ClosesAt: "14:00"}},
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	slots = ReservationSlots(day, s)
+
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 10, 20, 12, 0, 0, 0, berlin),
+		time.Date(2026, 10, 20, 12, 30, 0, 0, berlin),
+		time.Date(2026, 10, 20, 13, 0, 0, 0, berlin),
+		time.Date(2026, 10, 20, 13, 30, 0, 0, berlin),
+	}, slots)
+	assert.True(t, IsReservationSlot(time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC), s))
+	assert.False(t, IsReservationSlot(time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC), s))
+
+	s.Closures = []*Closure{{StartsOn: "2026-10-20", EndsOn: "2026-10-20"}}
+	assert.Empty(t, ReservationSlots(day, s))
+
+}
+
+func TestAvailableSlots(t *testing.T) {
+
+	start := time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC)
+	slots := []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}
+
+	tables := []*Table{
+		{ID: 1, Seats: 2},
+		{ID: 2, Seats: 4},
+	}
+
+	booked := []*Reservation{
+		{TableID: 2, StartsAt: start, EndsAt: start.Add(ReservationDuration), Status: ReservationStatusBooked},
+		{TableID: 1, StartsAt: start, EndsAt: start.Add(ReservationDuration), Status: ReservationStatusCancelled},
+	}
+
+	available := AvailableSlots(slots, tables, booked, 4)
+	assert.Len(t, available, 1)
+	assert.Equal(t, start.Add(2*time.Hour), available[0].StartsAt)
+
+	available = AvailableSlots(slots, tables, booked, 2)
+	assert.Len(t, available, 3)
+	assert.Equal(t, 1, available[0].FreeTables)
+	assert.Equal(t, 2, available[2].FreeTables)
+
+}
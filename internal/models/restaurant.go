@@ -11,6 +11,14 @@ import (
 	"github.com/geekilx/restaurantAPI/internal/validator"
 )
 
+// A new restaurant waits for an admin to review it, only approved restaurants
+// are shown to the public.
+const (
+	RestaurantPendingReview = "pending_review"
+	RestaurantApproved      = "approved"
+	RestaurantRejected      = "rejected"
+)
+
 type Restaurant struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
@@ -18,24 +26,47 @@ type Restaurant struct {
 	FullAddress string    `json:"full_address"`
 	Cuisine     string    `json:"cuisine"`
 	Status      string    `json:"status"`
+	Timezone    string    `json:"timezone"`
+	BrandID     *int64    `json:"brand_id,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// set by the admins reviewing the restaurant, see Review
+	ReviewStatus string     `json:"review_status"`
+	ReviewReason *string    `json:"review_reason,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+
+	// computed from the opening hours, see ApplySchedule
+	IsOpenNow     bool       `json:"is_open_now"`
+	NextOpeningAt *time.Time `json:"next_opening_at"`
+
+	// aggregated from the visible reviews of the restaurant
+	Rating      float64 `json:"rating"`
+	ReviewCount int     `json:"review_count"`
 }
 
 type RestaurantModel struct {
 	DB *sql.DB
 }
 
-func (m *RestaurantModel) Insert(restaurant *Restaurant) (int64, error) {
-	stmt := `INSERT INTO restaurant (name, country, full_address, cuisine, status) VALUES($1, $2, $3, $4, $5) 
-	RETURNING id, created_at, updated_at`
+// Insert creates the restaurant, links it to the account of the seller and
+// makes the seller its first owner in one transaction.
+func (m *RestaurantModel) Insert(restaurant *Restaurant, ownerID int64) (int64, error) {
+	stmt := `INSERT INTO restaurant (name, country, full_address, cuisine, status, timezone) VALUES($1, $2, $3, $4, $5, $6)
+	RETURNING id, review_status, created_at, updated_at`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	args := []any{restaurant.Name, restaurant.Country, restaurant.FullAddress, restaurant.Cuisine, restaurant.Status}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	args := []any{restaurant.Name, restaurant.Country, restaurant.FullAddress, restaurant.Cuisine, restaurant.Status, restaurant.Timezone}
 
-	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&restaurant.ID, &restaurant.CreatedAt, &restaurant.UpdatedAt)
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&restaurant.ID, &restaurant.ReviewStatus, &restaurant.CreatedAt, &restaurant.UpdatedAt)
 	if err != nil {
 		switch {
 		case strings.Contains(err.Error(), "restaurant_name_key"):
@@ -44,18 +75,58 @@ func (m *RestaurantModel) Insert(restaurant *Restaurant) (int64, error) {
 			return 0, err
 		}
 	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET restaurant_id = $1 WHERE id = $2 AND restaurant_id IS NULL`, restaurant.ID, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if rowsAffected == 0 {
+		return 0, ErrUserHasRestaurant
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO restaurant_members (restaurant_id, user_id, role) VALUES($1, $2, $3)`, restaurant.ID, ownerID, StaffOwner)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
 	return restaurant.ID, nil
 
 }
 
-func (m *RestaurantModel) GetAll(name string, f Filters) ([]*Restaurant, Metadata, error) {
-	stmt := fmt.Sprintf(`SELECT count(*) OVER(), * FROM restaurant WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		ORDER BY %s %s, id ASC LIMIT %d OFFSET %d`, f.sortColumn(), f.sortDirection(), f.Limit(), f.Offset())
+// GetAll searches the restaurants in the reviewStatus, in any status when it's empty.
+func (m *RestaurantModel) GetAll(name string, openNow bool, reviewStatus string, f Filters) ([]*Restaurant, Metadata, error) {
+
+	sortColumn := "r." + f.sortColumn()
+	switch f.sortColumn() {
+	case "rating":
+		sortColumn = "COALESCE(rt.rating, 0)"
+	case "review_count":
+		sortColumn = "COALESCE(rt.review_count, 0)"
+	}
+
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), r.id, r.name, r.country, r.full_address, r.cuisine, r.status, r.timezone, r.created_at, r.updated_at,
+		r.review_status, r.review_reason, r.reviewed_at, COALESCE(rt.rating, 0), COALESCE(rt.review_count, 0) FROM restaurant r
+		LEFT JOIN %s rt on rt.restaurant_id = r.id
+		WHERE (to_tsvector('simple', r.name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND ($2 = false OR %s)
+		AND (r.review_status = $3 OR $3 = '')
+		ORDER BY %s %s, r.id ASC LIMIT %d OFFSET %d`, restaurantRatings, openNowCondition, sortColumn, f.sortDirection(), f.Limit(), f.Offset())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, stmt, name)
+	rows, err := m.DB.QueryContext(ctx, stmt, name, openNow, reviewStatus)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -68,7 +139,8 @@ func (m *RestaurantModel) GetAll(name string, f Filters) ([]*Restaurant, Metadat
 	for rows.Next() {
 		var restaurant Restaurant
 
-		err := rows.Scan(&totalRecords, &restaurant.ID, &restaurant.Name, &restaurant.Country, &restaurant.FullAddress, &restaurant.Cuisine, &restaurant.Status, &restaurant.CreatedAt, &restaurant.UpdatedAt)
+		err := rows.Scan(&totalRecords, &restaurant.ID, &restaurant.Name, &restaurant.Country, &restaurant.FullAddress, &restaurant.Cuisine, &restaurant.Status, &restaurant.Timezone, &restaurant.CreatedAt, &restaurant.UpdatedAt,
+			&restaurant.ReviewStatus, &restaurant.ReviewReason, &restaurant.ReviewedAt, &restaurant.Rating, &restaurant.ReviewCount)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -83,14 +155,17 @@ func (m *RestaurantModel) GetAll(name string, f Filters) ([]*Restaurant, Metadat
 
 }
 
+// Update changes the restaurant, a rejected restaurant goes back to the review queue.
 func (m *RestaurantModel) Update(id int64, restaurant Restaurant) error {
 
-	stmt := `UPDATE restaurant SET name = $1, country = $2, full_address = $3, cuisine = $4, status = $5, updated_at = NOW() WHERE id = $6`
+	stmt := `UPDATE restaurant SET name = $1, country = $2, full_address = $3, cuisine = $4, status = $5, timezone = $6, updated_at = NOW(),
+	review_status = CASE WHEN review_status = 'rejected' THEN 'pending_review' ELSE review_status END
+	WHERE id = $7`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{restaurant.Name, restaurant.Country, restaurant.FullAddress, restaurant.Cuisine, restaurant.Status, id}
+	args := []any{restaurant.Name, restaurant.Country, restaurant.FullAddress, restaurant.Cuisine, restaurant.Status, restaurant.Timezone, id}
 
 	rows, err := m.DB.ExecContext(ctx, stmt, args...)
 	if err != nil {
@@ -116,13 +191,17 @@ func (m *RestaurantModel) Update(id int64, restaurant Restaurant) error {
 }
 
 func (m *RestaurantModel) Get(id int64) (*Restaurant, error) {
-	stmt := `SELECT * FROM restaurant WHERE id = $1`
+	stmt := fmt.Sprintf(`SELECT r.id, r.name, r.country, r.full_address, r.cuisine, r.status, r.timezone, r.brand_id, r.created_at, r.updated_at,
+	r.review_status, r.review_reason, r.reviewed_at, COALESCE(rt.rating, 0), COALESCE(rt.review_count, 0) FROM restaurant r
+	LEFT JOIN %s rt on rt.restaurant_id = r.id
+	WHERE r.id = $1`, restaurantRatings)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var restaurant Restaurant
-	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&restaurant.ID, &restaurant.Name, &restaurant.Country, &restaurant.FullAddress, &restaurant.Cuisine, &restaurant.Status, &restaurant.CreatedAt, &restaurant.UpdatedAt)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&restaurant.ID, &restaurant.Name, &restaurant.Country, &restaurant.FullAddress, &restaurant.Cuisine, &restaurant.Status, &restaurant.Timezone, &restaurant.BrandID, &restaurant.CreatedAt, &restaurant.UpdatedAt,
+		&restaurant.ReviewStatus, &restaurant.ReviewReason, &restaurant.ReviewedAt, &restaurant.Rating, &restaurant.ReviewCount)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -135,6 +214,27 @@ func (m *RestaurantModel) Get(id int64) (*Restaurant, error) {
 	return &restaurant, nil
 }
 
+// Review records the decision of an admin on the restaurant.
+func (m *RestaurantModel) Review(restaurant *Restaurant) error {
+	stmt := `UPDATE restaurant SET review_status = $1, review_reason = $2, reviewed_at = NOW(), updated_at = NOW() WHERE id = $3
+	RETURNING reviewed_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, restaurant.ReviewStatus, restaurant.ReviewReason, restaurant.ID).Scan(&restaurant.ReviewedAt, &restaurant.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRestaurantNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *RestaurantModel) Delete(id int64) error {
 	stmt := `DELETE FROM restaurant where id = $1`
 
@@ -185,4 +285,14 @@ func ValidateRestaurant(v *validator.Validator, res Restaurant) {
 	v.Check(len(res.Cuisine) < 3 || len(res.Cuisine) > 50, "cuisine", "cuisine must be greater than 3 and less than 50 characters")
 
 	v.Check(!validator.PermittedValue(res.Status, "open", "closed"), "status", "you have to provide valid status (open,closed)")
+
+	ValidateTimezone(v, res.Timezone)
+}
+
+// ValidateRestaurantReview checks the decision of an admin, a rejection has to
+// tell the seller why.
+func ValidateRestaurantReview(v *validator.Validator, status string, reason *string) {
+	v.Check(!validator.PermittedValue(status, RestaurantApproved, RestaurantRejected), "status", "status must be approved or rejected")
+	v.Check(status == RestaurantRejected && (reason == nil || v.Empty(*reason)), "reason", "a reason must be provided when rejecting a restaurant")
+	v.Check(reason != nil && len(*reason) > 500, "reason", "reason must not be more than 500 characters")
 }
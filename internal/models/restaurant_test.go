@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRestaurantReview(t *testing.T) {
+
+	reason := "the address could not be verified"
+	blank := ""
+	long := strings.Repeat("a", 501)
+
+	v := validator.New()
+	ValidateRestaurantReview(v, RestaurantApproved, nil)
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateRestaurantReview(v, RestaurantRejected, &reason)
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateRestaurantReview(v, RestaurantPendingReview, nil)
+	assert.Contains(t, v.FieldErorrs, "status")
+
+	v = validator.New()
+	ValidateRestaurantReview(v, RestaurantRejected, nil)
+	assert.Contains(t, v.FieldErorrs, "reason")
+
+	v = validator.New()
+	ValidateRestaurantReview(v, RestaurantRejected, &blank)
+	assert.Contains(t, v.FieldErorrs, "reason")
+
+	v = validator.New()
+	ValidateRestaurantReview(v, RestaurantApproved, &long)
+	assert.Contains(t, v.FieldErorrs, "reason")
+
+}
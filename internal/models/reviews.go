@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+)
+
+// restaurantRatings aggregates the visible reviews of the restaurants, reviews
+// of single menu items don't count towards the restaurant rating.
+const restaurantRatings = `(SELECT restaurant_id, round(avg(rating), 2) AS rating, count(*) AS review_count FROM reviews
+	WHERE menu_id IS NULL AND NOT is_hidden GROUP BY restaurant_id)`
+
+type Review struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	AuthorName   string     `json:"author_name"`
+	RestaurantID int64      `json:"restaurant_id"`
+	MenuID       *int64     `json:"menu_id"`
+	Rating       int        `json:"rating"`
+	Body         string     `json:"body"`
+	Reply        *string    `json:"reply"`
+	RepliedAt    *time.Time `json:"replied_at"`
+	IsHidden     bool       `json:"is_hidden"`
+	HiddenReason string     `json:"hidden_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type ReviewSummary struct {
+	Rating      float64 `json:"rating"`
+	ReviewCount int     `json:"review_count"`
+}
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+func (m *ReviewModel) Insert(review *Review) error {
+	stmt := `INSERT INTO reviews (user_id, restaurant_id, menu_id, rating, body) VALUES($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{review.UserID, review.RestaurantID, review.MenuID, review.Rating, review.Body}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "reviews_user_restaurant_key"), strings.Contains(err.Error(), "reviews_user_menu_key"):
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+func (m *ReviewModel) Get(id int64) (*Review, error) {
+	stmt := `SELECT rv.id, rv.user_id, u.first_name, rv.restaurant_id, rv.menu_id, rv.rating, rv.body, rv.reply, rv.replied_at, rv.is_hidden, rv.hidden_reason,
+	rv.created_at, rv.updated_at FROM reviews rv
+	INNER JOIN users u on u.id = rv.user_id
+	WHERE rv.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var review Review
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&review.ID, &review.UserID, &review.AuthorName, &review.RestaurantID, &review.MenuID, &review.Rating, &review.Body,
+		&review.Reply, &review.RepliedAt, &review.IsHidden, &review.HiddenReason, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+
+}
+
+// GetAllForRestaurant lists the visible reviews of a restaurant. When menuID is
+// set only the reviews of that menu item are returned, otherwise only the
+// reviews of the restaurant itself.
+func (m *ReviewModel) GetAllForRestaurant(restaurantID int64, menuID *int64, f Filters) ([]*Review, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), rv.id, rv.user_id, u.first_name, rv.restaurant_id, rv.menu_id, rv.rating, rv.body, rv.reply, rv.replied_at,
+	rv.is_hidden, rv.hidden_reason, rv.created_at, rv.updated_at FROM reviews rv
+	INNER JOIN users u on u.id = rv.user_id
+	WHERE rv.restaurant_id = $1 AND NOT rv.is_hidden
+	AND (($2::bigint IS NULL AND rv.menu_id IS NULL) OR rv.menu_id = $2)
+	ORDER BY rv.%s %s, rv.id DESC LIMIT %d OFFSET %d`, f.sortColumn(), f.sortDirection(), f.Limit(), f.Offset())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, restaurantID, menuID)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	var totalRecords int
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(&totalRecords, &review.ID, &review.UserID, &review.AuthorName, &review.RestaurantID, &review.MenuID, &review.Rating, &review.Body,
+			&review.Reply, &review.RepliedAt, &review.IsHidden, &review.HiddenReason, &review.CreatedAt, &review.UpdatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return reviews, CalculateMetadata(totalRecords, f.Page, f.PageSize), nil
+
+}
+
+// Summary returns the average rating and the number of visible reviews of a
+// restaurant, or of one of its menu items when menuID is set.
+func (m *ReviewModel) Summary(restaurantID int64, menuID *int64) (*ReviewSummary, error) {
+	stmt := `SELECT COALESCE(round(avg(rating), 2), 0), count(*) FROM reviews
+	WHERE restaurant_id = $1 AND NOT is_hidden AND (($2::bigint IS NULL AND menu_id IS NULL) OR menu_id = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var summary ReviewSummary
+
+	err := m.DB.QueryRowContext(ctx, stmt, restaurantID, menuID).Scan(&summary.Rating, &summary.ReviewCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+
+}
+
+func (m *ReviewModel) Update(review *Review) error {
+	stmt := `UPDATE reviews SET rating = $1, body = $2, updated_at = NOW() WHERE id = $3 AND updated_at = $4 RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, review.Rating, review.Body, review.ID, review.UpdatedAt).Scan(&review.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrConflictEdit
+		default:
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// SetReply stores the seller's public answer, a review has a single reply which
+// the seller can rewrite.
+func (m *ReviewModel) SetReply(review *Review, reply string) error {
+	stmt := `UPDATE reviews SET reply = $1, replied_at = NOW() WHERE id = $2 RETURNING replied_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, reply, review.ID).Scan(&review.RepliedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	review.Reply = &reply
+
+	return nil
+
+}
+
+func (m *ReviewModel) SetHidden(review *Review, hidden bool, reason string) error {
+	stmt := `UPDATE reviews SET is_hidden = $1, hidden_reason = $2 WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, hidden, reason, review.ID)
+	if err != nil {
+		return err
+	}
+
+	review.IsHidden = hidden
+	review.HiddenReason = reason
+
+	return nil
+
+}
+
+func (m *ReviewModel) Delete(id int64) error {
+	stmt := `DELETE FROM reviews WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+
+}
+
+// HasCompletedOrder reports whether the user received a completed order from
+// the restaurant, containing the menu item when menuID is set.
+func (m *ReviewModel) HasCompletedOrder(userID, restaurantID int64, menuID *int64) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT FROM orders o
+	WHERE o.user_id = $1 AND o.restaurant_id = $2 AND o.status = 'completed'
+	AND ($3::bigint IS NULL OR EXISTS(SELECT FROM order_items oi WHERE oi.order_id = o.id AND oi.menu_id = $3)))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var ok bool
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, restaurantID, menuID).Scan(&ok)
+	if err != nil {
+		return false, err
+	}
+
+	return ok, nil
+
+}
+
+func ValidateReview(v *validator.Validator, review Review) {
+	v.Check(review.Rating < 1 || review.Rating > 5, "rating", "rating must be between 1 and 5")
+	v.Check(len(review.Body) > 2000, "body", "review must not be more than 2000 characters")
+}
+
+func ValidateReply(v *validator.Validator, reply string) {
+	v.Check(v.Empty(strings.TrimSpace(reply)), "reply", "reply must be provided")
+	v.Check(len(reply) > 2000, "reply", "reply must not be more than 2000 characters")
+}
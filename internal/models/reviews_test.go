@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReview(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		review Review
+		valid  bool
+	}{
+		{"rating only", Review{Rating: 5}, true},
+		{"with body", Review{Rating: 3, Body: "good pizza, slow delivery"}, true},
+		{"rating too low", Review{Rating: 0}, false},
+		{"rating too high", Review{Rating: 6}, false},
+		{"body too long", Review{Rating: 4, Body: strings.Repeat("a", 2001)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateReview(v, tt.review)
+			assert.Equal(t, tt.valid, v.Valid())
+		})
+	}
+
+	v := validator.New()
+	ValidateReply(v, "   ")
+	assert.False(t, v.Valid())
+
+}
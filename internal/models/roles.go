@@ -0,0 +1,346 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+var roleNameRX = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Role is a named bundle of permission codes, users get the permissions of
+// their roles on top of the ones granted to them directly. The roles named
+// like UserRoles are given to new users according to users.role, StaffRole to
+// users joining the staff of a restaurant.
+type Role struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Permissions Permissions `json:"permissions"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// IsBuiltIn reports whether the role is handed out by the API itself.
+func (role *Role) IsBuiltIn() bool {
+	return slices.Contains(UserRoles, role.Name) || role.Name == StaffRole
+}
+
+type RoleModel struct {
+	DB *sql.DB
+}
+
+func (m *RoleModel) Insert(role *Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO roles (name, description) VALUES($1, $2) RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, stmt, role.Name, role.Description).Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "roles_name_key"):
+			return ErrDuplicateRoleName
+		default:
+			return err
+		}
+	}
+
+	err = setRolePermissions(ctx, tx, role.ID, role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const roleColumns = `r.id, r.name, r.description, r.created_at, r.updated_at,
+	COALESCE(array_agg(p.code ORDER BY p.code) FILTER (WHERE p.code IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN roles_permissions rp ON rp.role_id = r.id
+	LEFT JOIN permissions p ON p.id = rp.permission_id`
+
+func scanRole(row interface{ Scan(...any) error }) (*Role, error) {
+	var role Role
+
+	err := row.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, pq.Array(&role.Permissions))
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (m *RoleModel) GetAll() ([]*Role, error) {
+	stmt := `SELECT ` + roleColumns + ` GROUP BY r.id ORDER BY r.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	roles := []*Role{}
+
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (m *RoleModel) Get(id int64) (*Role, error) {
+	stmt := `SELECT ` + roleColumns + ` WHERE r.id = $1 GROUP BY r.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	role, err := scanRole(m.DB.QueryRowContext(ctx, stmt, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return role, nil
+}
+
+// Update saves the description and replaces the permissions of the role, it
+// fails with ErrConflictEdit when the role was changed since it was read.
+func (m *RoleModel) Update(role *Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE roles SET description = $1, updated_at = NOW() WHERE id = $2 AND updated_at = $3 RETURNING updated_at`
+
+	err = tx.QueryRowContext(ctx, stmt, role.Description, role.ID, role.UpdatedAt).Scan(&role.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrConflictEdit
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM roles_permissions WHERE role_id = $1`, role.ID)
+	if err != nil {
+		return err
+	}
+
+	err = setRolePermissions(ctx, tx, role.ID, role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *RoleModel) Delete(id int64) error {
+	stmt := `DELETE FROM roles WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetUserIDs returns the users holding the role, their cached permissions
+// are outdated once the role changes.
+func (m *RoleModel) GetUserIDs(roleID int64) ([]int64, error) {
+	stmt := `SELECT user_id FROM users_roles WHERE role_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var userIDs []int64
+
+	for rows.Next() {
+		var userID int64
+
+		err = rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+func (m *RoleModel) GetForUser(userID int64) ([]string, error) {
+	stmt := `SELECT r.name FROM roles r INNER JOIN users_roles ur ON ur.role_id = r.id WHERE ur.user_id = $1 ORDER BY r.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	names := []string{}
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// AddForUser gives the role to the user, ErrRecordNotFound when there is no such role.
+func (m *RoleModel) AddForUser(userID int64, name string) error {
+	stmt := `INSERT INTO users_roles (user_id, role_id) SELECT $1, id FROM roles WHERE name = $2
+	ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT FROM roles WHERE name = $1)`, name).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrRecordNotFound
+	}
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, name)
+	return err
+}
+
+func (m *RoleModel) RemoveForUser(userID int64, name string) error {
+	stmt := `DELETE FROM users_roles WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, name)
+	return err
+}
+
+// ReplaceForUser sets users.role to the new role and swaps the template role of
+// the old one for the one of the new in one transaction, a missing template
+// role is skipped.
+func (m *RoleModel) ReplaceForUser(userID int64, oldName, newName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET role = $1, last_updated = NOW() WHERE id = $2`, newName, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users_roles WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)`, userID, oldName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO users_roles (user_id, role_id) SELECT $1, id FROM roles WHERE name = $2
+	ON CONFLICT DO NOTHING`, userID, newName)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setRolePermissions(ctx context.Context, tx *sql.Tx, roleID int64, codes Permissions) error {
+	stmt := `INSERT INTO roles_permissions (role_id, permission_id)
+	SELECT $1, id FROM permissions WHERE code = ANY($2)`
+
+	_, err := tx.ExecContext(ctx, stmt, roleID, pq.Array(codes))
+	return err
+}
+
+// ValidateRole checks the role, known holds every existing permission code.
+func ValidateRole(v *validator.Validator, role Role, known Permissions) {
+	v.Check(v.Empty(role.Name), "name", "name must be provided")
+	v.Check(len(role.Name) > 50, "name", "name must not be more than 50 characters")
+	v.Check(role.Name != "" && !roleNameRX.MatchString(role.Name), "name", "name must be lowercase letters, digits, dashes and underscores")
+	v.Check(len(role.Description) > 500, "description", "description must not be more than 500 characters")
+	v.Check(!validator.Unique(role.Permissions), "permissions", "permissions must not contain duplicates")
+
+	for _, code := range role.Permissions {
+		v.Check(!known.Include(code), "permissions", "unknown permission "+code)
+	}
+}
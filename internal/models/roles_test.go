@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleIsBuiltIn(t *testing.T) {
+
+	assert.True(t, (&Role{Name: "seller"}).IsBuiltIn())
+	assert.True(t, (&Role{Name: StaffRole}).IsBuiltIn())
+	assert.False(t, (&Role{Name: "support"}).IsBuiltIn())
+
+}
+
+func TestValidateRole(t *testing.T) {
+
+	known := Permissions{"restaurant:read", "restaurant:write", "admin:users:read"}
+
+	v := validator.New()
+	ValidateRole(v, Role{Name: "support", Permissions: Permissions{"restaurant:read", "admin:users:read"}}, known)
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateRole(v, Role{Name: "Support Team"}, known)
+	assert.Contains(t, v.FieldErorrs, "name")
+
+	v = validator.New()
+	ValidateRole(v, Role{Name: "support", Permissions: Permissions{"restaurant:read", "restaurant:read"}}, known)
+	assert.Contains(t, v.FieldErorrs, "permissions")
+
+	v = validator.New()
+	ValidateRole(v, Role{Name: "support", Permissions: Permissions{"orders:delete"}}, known)
+	assert.Equal(t, "unknown permission orders:delete", v.FieldErorrs["permissions"])
+
+}
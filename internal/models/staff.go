@@ -0,0 +1,487 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	StaffOwner   = "owner"
+	StaffManager = "manager"
+	StaffCashier = "cashier"
+	StaffKitchen = "kitchen"
+)
+
+// StaffRoles are the roles a member can have in the staff of a restaurant.
+var StaffRoles = []string{StaffOwner, StaffManager, StaffCashier, StaffKitchen}
+
+// RestaurantManagers may change the restaurant, its menu and its staff.
+var RestaurantManagers = []string{StaffOwner, StaffManager}
+
+// StaffRole is the role given to users who joined the staff of a restaurant
+// through an invitation, it carries the permissions the staff routes require.
+const StaffRole = "staff"
+
+// Member is a user working at a restaurant.
+type Member struct {
+	RestaurantID int64     `json:"restaurant_id"`
+	UserID       int64     `json:"user_id"`
+	FirstName    string    `json:"first_name"`
+	LastName     string    `json:"last_name"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Invitation asks the owner of an email address to join the staff of a
+// restaurant or the members of a brand, only one of RestaurantID and BrandID is
+// set. It is accepted with the token of InvitationScope sent by email.
+type Invitation struct {
+	ID           int64     `json:"id"`
+	RestaurantID *int64    `json:"restaurant_id,omitempty"`
+	BrandID      *int64    `json:"brand_id,omitempty"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	InvitedBy    int64     `json:"invited_by"`
+	Expiry       time.Time `json:"expiry"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type StaffModel struct {
+	DB *sql.DB
+}
+
+// GetRole returns the role of the user at the restaurant, ErrRecordNotFound
+// when the user isn't a member of its staff. The members of the restaurant's
+// brand have their brand role at every branch, the highest role wins.
+func (m *StaffModel) GetRole(restaurantID, userID int64) (string, error) {
+	stmt := `SELECT role FROM (
+		SELECT role FROM restaurant_members WHERE restaurant_id = $1 AND user_id = $2
+		UNION ALL
+		SELECT bm.role FROM brand_members bm INNER JOIN restaurant r ON r.brand_id = bm.brand_id
+		WHERE r.id = $1 AND bm.user_id = $2
+	) roles
+	ORDER BY array_position($3, role) LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var role string
+
+	err := m.DB.QueryRowContext(ctx, stmt, restaurantID, userID, pq.Array(StaffRoles)).Scan(&role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
+const memberColumns = `m.restaurant_id, m.user_id, u.first_name, u.last_name, u.email, m.role, m.created_at
+	FROM restaurant_members m INNER JOIN users u ON u.id = m.user_id`
+
+func scanMember(row interface{ Scan(...any) error }) (*Member, error) {
+	var member Member
+
+	err := row.Scan(&member.RestaurantID, &member.UserID, &member.FirstName, &member.LastName, &member.Email, &member.Role, &member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (m *StaffModel) GetAll(restaurantID int64) ([]*Member, error) {
+	stmt := `SELECT ` + memberColumns + ` WHERE m.restaurant_id = $1 ORDER BY m.created_at, m.user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []*Member{}
+
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (m *StaffModel) Get(restaurantID, userID int64) (*Member, error) {
+	stmt := `SELECT ` + memberColumns + ` WHERE m.restaurant_id = $1 AND m.user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	member, err := scanMember(m.DB.QueryRowContext(ctx, stmt, restaurantID, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return member, nil
+}
+
+// UpdateRole changes the role of a member, it fails with ErrLastOwner when the
+// member is the only owner left.
+func (m *StaffModel) UpdateRole(restaurantID, userID int64, role string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != StaffOwner {
+		err = checkNotLastOwner(ctx, tx, "restaurant_members", "restaurant_id", restaurantID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE restaurant_members SET role = $1 WHERE restaurant_id = $2 AND user_id = $3`, role, restaurantID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Remove takes the user off the staff of the restaurant, it fails with
+// ErrLastOwner when the member is the only owner left.
+func (m *StaffModel) Remove(restaurantID, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkNotLastOwner(ctx, tx, "restaurant_members", "restaurant_id", restaurantID, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM restaurant_members WHERE restaurant_id = $1 AND user_id = $2`, restaurantID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// IsMemberAnywhere reports whether the user is still on the staff of a
+// restaurant or a member of a brand.
+func (m *StaffModel) IsMemberAnywhere(userID int64) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT FROM restaurant_members WHERE user_id = $1)
+	OR EXISTS(SELECT FROM brand_members WHERE user_id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(&exists)
+	return exists, err
+}
+
+// checkNotLastOwner locks the owners in the members table of a restaurant or
+// brand and returns ErrLastOwner when userID is the only one of them.
+func checkNotLastOwner(ctx context.Context, tx *sql.Tx, table, column string, id, userID int64) error {
+	stmt := fmt.Sprintf(`SELECT user_id FROM %s WHERE %s = $1 AND role = $2 FOR UPDATE`, table, column)
+
+	rows, err := tx.QueryContext(ctx, stmt, id, StaffOwner)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var owners []int64
+
+	for rows.Next() {
+		var owner int64
+
+		err = rows.Scan(&owner)
+		if err != nil {
+			return err
+		}
+
+		owners = append(owners, owner)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// Invite stores the invitation with its token, an earlier invitation of the
+// same address to the restaurant or brand is replaced.
+func (m *StaffModel) Invite(invitation *Invitation, token *Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the invitation goes away with its token
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE hash IN
+	(SELECT token_hash FROM restaurant_invitations
+	WHERE restaurant_id IS NOT DISTINCT FROM $1 AND brand_id IS NOT DISTINCT FROM $2 AND lower(email) = lower($3))`, invitation.RestaurantID, invitation.BrandID, invitation.Email)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO tokens (hash, user_id, expiry, scope) VALUES($1, $2, $3, $4)`, token.Hash, token.UserID, token.Expiry, token.Scope)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO restaurant_invitations (token_hash, restaurant_id, brand_id, email, role, invited_by)
+	VALUES($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, stmt, token.Hash, invitation.RestaurantID, invitation.BrandID, invitation.Email, invitation.Role, invitation.InvitedBy).Scan(&invitation.ID, &invitation.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	invitation.Expiry = token.Expiry
+
+	return tx.Commit()
+}
+
+const invitationColumns = `i.id, i.restaurant_id, i.brand_id, i.email, i.role, i.invited_by, t.expiry, i.created_at
+	FROM restaurant_invitations i INNER JOIN tokens t ON t.hash = i.token_hash`
+
+func scanInvitation(row interface{ Scan(...any) error }) (*Invitation, error) {
+	var invitation Invitation
+
+	err := row.Scan(&invitation.ID, &invitation.RestaurantID, &invitation.BrandID, &invitation.Email, &invitation.Role, &invitation.InvitedBy, &invitation.Expiry, &invitation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// GetInvitations returns the invitations of the restaurant that can still be accepted.
+func (m *StaffModel) GetInvitations(restaurantID int64) ([]*Invitation, error) {
+	return m.getInvitations("restaurant_id", restaurantID)
+}
+
+// GetBrandInvitations returns the invitations of the brand that can still be accepted.
+func (m *StaffModel) GetBrandInvitations(brandID int64) ([]*Invitation, error) {
+	return m.getInvitations("brand_id", brandID)
+}
+
+func (m *StaffModel) getInvitations(column string, id int64) ([]*Invitation, error) {
+	stmt := fmt.Sprintf(`SELECT %s
+	WHERE i.%s = $1 AND t.expiry > $2
+	ORDER BY i.created_at DESC`, invitationColumns, column)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation deletes the token of the invitation, and so the invitation.
+func (m *StaffModel) RevokeInvitation(restaurantID, invitationID int64) error {
+	return m.revokeInvitation("restaurant_id", restaurantID, invitationID)
+}
+
+// RevokeBrandInvitation deletes the token of the brand's invitation.
+func (m *StaffModel) RevokeBrandInvitation(brandID, invitationID int64) error {
+	return m.revokeInvitation("brand_id", brandID, invitationID)
+}
+
+func (m *StaffModel) revokeInvitation(column string, id, invitationID int64) error {
+	stmt := fmt.Sprintf(`DELETE FROM tokens WHERE hash = (SELECT token_hash FROM restaurant_invitations WHERE id = $1 AND %s = $2)`, column)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, invitationID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// AcceptInvitation adds the user to the staff of the restaurant or to the
+// members of the brand with the role of the invitation. The invitation has to
+// be addressed to the user's email, any other token gives ErrRecordNotFound.
+// The last owner accepting a lower role fails with ErrLastOwner.
+func (m *StaffModel) AcceptInvitation(plainToken string, user *User) (*Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(plainToken))
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + invitationColumns + `
+	WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
+	FOR UPDATE`
+
+	invitation, err := scanInvitation(tx.QueryRowContext(ctx, stmt, hash[:], InvitationScope, time.Now()))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, ErrRecordNotFound
+	}
+
+	table, column, id := "restaurant_members", "restaurant_id", invitation.RestaurantID
+	if invitation.BrandID != nil {
+		table, column, id = "brand_members", "brand_id", invitation.BrandID
+	}
+
+	if invitation.Role != StaffOwner {
+		err = checkNotLastOwner(ctx, tx, table, column, *id, user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (%s, user_id, role) VALUES($1, $2, $3)
+	ON CONFLICT (%s, user_id) DO UPDATE SET role = EXCLUDED.role`, table, column, column), *id, user.ID, invitation.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE hash = $1`, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return invitation, tx.Commit()
+}
+
+// CanManageStaff reports whether a member with the actor role may invite,
+// change or remove members with the given role. Owners manage everyone,
+// managers only the cashiers and the kitchen.
+func CanManageStaff(actor, role string) bool {
+	switch actor {
+	case StaffOwner:
+		return true
+	case StaffManager:
+		return !slices.Contains(RestaurantManagers, role)
+	default:
+		return false
+	}
+}
+
+func ValidateStaffRole(v *validator.Validator, role string) {
+	v.Check(v.Empty(role), "role", "role must be provided")
+	v.Check(role != "" && !slices.Contains(StaffRoles, role), "role", "role must be one of "+strings.Join(StaffRoles, ", "))
+}
+
+func ValidateInvitation(v *validator.Validator, invitation Invitation) {
+	v.Check(v.Empty(invitation.Email), "email", "email must be provided")
+	v.Check(!validator.CheckEmail(invitation.Email, validator.EmailRX), "email", "you should provide a valid email address")
+
+	if invitation.BrandID != nil {
+		v.Check(!validator.PermittedValue(invitation.Role, BrandRoles...), "role", "role must be owner or manager")
+		return
+	}
+
+	ValidateStaffRole(v, invitation.Role)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanManageStaff(t *testing.T) {
+
+	for _, role := range StaffRoles {
+		assert.True(t, CanManageStaff(StaffOwner, role), role)
+	}
+
+	assert.True(t, CanManageStaff(StaffManager, StaffCashier))
+	assert.True(t, CanManageStaff(StaffManager, StaffKitchen))
+	assert.False(t, CanManageStaff(StaffManager, StaffManager))
+	assert.False(t, CanManageStaff(StaffManager, StaffOwner))
+	assert.False(t, CanManageStaff(StaffCashier, StaffKitchen))
+	assert.False(t, CanManageStaff(StaffKitchen, StaffKitchen))
+
+}
+
+func TestValidateInvitation(t *testing.T) {
+
+	v := validator.New()
+	ValidateInvitation(v, Invitation{Email: "cook@example.com", Role: StaffKitchen})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateInvitation(v, Invitation{Email: "not-an-email", Role: "waiter"})
+	assert.Contains(t, v.FieldErorrs, "email")
+	assert.Contains(t, v.FieldErorrs, "role")
+
+	// brands only have owners and managers
+	brandID := int64(1)
+
+	v = validator.New()
+	ValidateInvitation(v, Invitation{BrandID: &brandID, Email: "chef@example.com", Role: StaffManager})
+	assert.True(t, v.Valid())
+
+	v = validator.New()
+	ValidateInvitation(v, Invitation{BrandID: &brandID, Email: "cook@example.com", Role: StaffKitchen})
+	assert.Contains(t, v.FieldErorrs, "role")
+
+}
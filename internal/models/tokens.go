@@ -15,6 +15,11 @@ import (
 var (
 	ActivationScope     = "activation"
 	AuthenticationScope = "authentication"
+	PasswordResetScope  = "password-reset"
+	RefreshScope        = "refresh"
+	MFAPendingScope     = "mfa-pending"
+	EmailChangeScope    = "email-change"
+	InvitationScope     = "invitation"
 )
 
 type Token struct {
@@ -25,6 +30,16 @@ type Token struct {
 	Scope      string    `json:"-"`
 }
 
+// Session is a login as shown to its owner, made of the access and refresh
+// tokens of one family. The tokens themselves are never listed.
+type Session struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+	Current    bool       `json:"current"`
+}
+
 type TokenModel struct {
 	DB *sql.DB
 }
@@ -81,17 +96,19 @@ func (m TokenModel) Insert(token *Token) error {
 
 }
 
-func (m *TokenModel) GetByToken(plainToken string) (int64, error) {
+// GetByToken returns the owner of a token that has the given scope and is not
+// expired yet.
+func (m *TokenModel) GetByToken(scope, plainToken string) (int64, error) {
 
 	hash := sha256.Sum256([]byte(plainToken))
 
-	stmt := `SELECT user_id FROM tokens WHERE hash = $1`
+	stmt := `SELECT user_id FROM tokens WHERE hash = $1 AND scope = $2 AND expiry > $3`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var id int64
-	err := m.DB.QueryRowContext(ctx, stmt, hash[:]).Scan(&id)
+	err := m.DB.QueryRowContext(ctx, stmt, hash[:], scope, time.Now()).Scan(&id)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -105,6 +122,59 @@ func (m *TokenModel) GetByToken(plainToken string) (int64, error) {
 
 }
 
+// Touch records that an authentication token was just used.
+func (m *TokenModel) Touch(plainToken string) error {
+
+	hash := sha256.Sum256([]byte(plainToken))
+
+	stmt := `UPDATE tokens SET last_used_at = NOW() WHERE hash = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, hash[:])
+	return err
+
+}
+
+// GetSessions lists the logins of the user that still have a valid token, the
+// one the currentToken belongs to is flagged as the current session.
+func (m *TokenModel) GetSessions(userID int64, currentToken string) ([]*Session, error) {
+
+	hash := sha256.Sum256([]byte(currentToken))
+
+	stmt := `SELECT min(id), min(created_at), max(last_used_at), max(expiry), bool_or(hash = $1) FROM tokens
+	WHERE user_id = $2 AND scope IN ($3, $4) AND expiry > $5
+	GROUP BY COALESCE(family, encode(hash, 'hex'))
+	HAVING bool_or(used_at IS NULL)
+	ORDER BY max(COALESCE(last_used_at, created_at)) DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, hash[:], userID, AuthenticationScope, RefreshScope, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+
+		err := rows.Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.Expiry, &session.Current)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+
+}
+
 func (m *TokenModel) DeleteAllTokenForUser(userID int64, scope string) error {
 	stmt := `DELETE FROM tokens WHERE user_id = $1 AND scope = $2`
 
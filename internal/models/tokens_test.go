@@ -0,0 +1,33 @@
+package models
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/geekilx/restaurantAPI/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+
+	token, err := GenerateToken(45*time.Minute, 7, PasswordResetScope)
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte(token.PlainToken))
+
+	assert.Equal(t, hash[:], token.Hash)
+	assert.Equal(t, int64(7), token.UserID)
+	assert.Equal(t, PasswordResetScope, token.Scope)
+	assert.WithinDuration(t, time.Now().Add(45*time.Minute), token.Expiry, time.Second)
+
+	v := validator.New()
+	ValidateTokenPlaintext(v, token.PlainToken)
+	assert.True(t, v.Valid())
+
+	other, err := GenerateToken(time.Hour, 7, PasswordResetScope)
+	require.NoError(t, err)
+	assert.NotEqual(t, token.PlainToken, other.PlainToken)
+
+}
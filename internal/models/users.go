@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -222,6 +223,78 @@ func ValidatePassword(v *validator.Validator, password string) {
 	v.Check(len(password) < 6, "password", "password must be greater than 6 characters")
 }
 
+// UserRoles are the values the check_role_constarint allows.
+var UserRoles = []string{"customer", "seller", "admin"}
+
+// GetAll lists the users for the admin API. search matches the email and the
+// full name, role and isActive are only applied when set.
+func (m *UserModel) GetAll(search, role string, isActive *bool, f Filters) ([]*User, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), id, first_name, last_name, email, role, restaurant_id, is_active, created_at FROM users
+	WHERE ($1 = '' OR email ILIKE '%%' || $1 || '%%' OR (first_name || ' ' || last_name) ILIKE '%%' || $1 || '%%')
+	AND ($2 = '' OR role = $2)
+	AND ($3::boolean IS NULL OR is_active = $3)
+	ORDER BY %s %s, id ASC LIMIT %d OFFSET %d`, f.sortColumn(), f.sortDirection(), f.Limit(), f.Offset())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, search, role, isActive)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	var totalRecords int
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(&totalRecords, &user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.RestaurantID, &user.IsActive, &user.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return users, CalculateMetadata(totalRecords, f.Page, f.PageSize), nil
+
+}
+
+func (m *UserModel) SetActive(id int64, active bool) error {
+	stmt := `UPDATE users SET is_active = $1, last_updated = NOW() WHERE id = $2`
+
+	return m.exec(stmt, active, id)
+}
+
+// exec runs an update of a single user, ErrRecordNotFound when there is no such user.
+func (m *UserModel) exec(stmt string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func ValidateInformation(v *validator.Validator, user User, password string) {
 	v.Check(user.FirstName == "", "firstName", "you have to provide first name")
 	v.Check(user.LastName == "", "lastName", "you have to provide last name")
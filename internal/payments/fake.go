@@ -0,0 +1,153 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// FakeDeclinedMethod is the payment method the fake provider always declines.
+	FakeDeclinedMethod  = "fake_declined"
+	FakeSignatureHeader = "X-Fake-Signature"
+)
+
+type fakeAuthorization struct {
+	authorized int64
+	captured   int64
+	refunded   int64
+	voided     bool
+}
+
+// Fake is an in-process provider for development and tests. It never talks to
+// the network and its references are deterministic: the nth authorization is
+// always fake_auth_<n>.
+type Fake struct {
+	secret []byte
+
+	mu             sync.Mutex
+	counter        int64
+	authorizations map[string]*fakeAuthorization
+}
+
+func NewFake(webhookSecret string) *Fake {
+	return &Fake{
+		secret:         []byte(webhookSecret),
+		authorizations: make(map[string]*fakeAuthorization),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.AmountCent <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	if req.PaymentMethod == FakeDeclinedMethod {
+		return nil, ErrDeclined
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.counter++
+	reference := fmt.Sprintf("fake_auth_%d", f.counter)
+	f.authorizations[reference] = &fakeAuthorization{authorized: req.AmountCent}
+
+	return &Result{Reference: reference, AmountCent: req.AmountCent}, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, reference string, amountCent int64) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.authorizations[reference]
+	if !ok {
+		return nil, ErrUnknownReference
+	}
+
+	if auth.voided {
+		return nil, ErrVoided
+	}
+
+	if amountCent <= 0 || auth.captured+amountCent > auth.authorized {
+		return nil, ErrInvalidAmount
+	}
+
+	auth.captured += amountCent
+
+	return &Result{Reference: reference, AmountCent: amountCent}, nil
+}
+
+func (f *Fake) Void(ctx context.Context, reference string) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.authorizations[reference]
+	if !ok {
+		return nil, ErrUnknownReference
+	}
+
+	if auth.captured > 0 {
+		return nil, ErrAlreadyCaptured
+	}
+
+	auth.voided = true
+
+	return &Result{Reference: reference, AmountCent: auth.authorized}, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, reference string, amountCent int64) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.authorizations[reference]
+	if !ok {
+		return nil, ErrUnknownReference
+	}
+
+	if amountCent <= 0 || auth.refunded+amountCent > auth.captured {
+		return nil, ErrInvalidAmount
+	}
+
+	auth.refunded += amountCent
+	f.counter++
+
+	return &Result{Reference: fmt.Sprintf("fake_refund_%d", f.counter), AmountCent: amountCent}, nil
+}
+
+// Sign returns the signature the fake provider expects in the X-Fake-Signature header.
+func (f *Fake) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, header http.Header) (*WebhookEvent, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	err = json.Unmarshal(payload, &event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,92 @@
+package payments
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeAuthorizeCaptureRefund(t *testing.T) {
+
+	f := NewFake("secret")
+	ctx := context.Background()
+
+	res, err := f.Authorize(ctx, AuthorizeRequest{AmountCent: 1500, Currency: "EUR", PaymentMethod: "fake_card"})
+	require.NoError(t, err)
+	assert.Equal(t, "fake_auth_1", res.Reference)
+
+	_, err = f.Capture(ctx, res.Reference, 2000)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = f.Capture(ctx, res.Reference, 1500)
+	require.NoError(t, err)
+
+	_, err = f.Refund(ctx, res.Reference, 1000)
+	require.NoError(t, err)
+
+	_, err = f.Refund(ctx, res.Reference, 600)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = f.Capture(ctx, "fake_auth_404", 100)
+	assert.ErrorIs(t, err, ErrUnknownReference)
+
+}
+
+func TestFakeVoid(t *testing.T) {
+
+	f := NewFake("secret")
+	ctx := context.Background()
+
+	res, err := f.Authorize(ctx, AuthorizeRequest{AmountCent: 1500, Currency: "EUR", PaymentMethod: "fake_card"})
+	require.NoError(t, err)
+
+	_, err = f.Void(ctx, res.Reference)
+	require.NoError(t, err)
+
+	_, err = f.Capture(ctx, res.Reference, 1500)
+	assert.ErrorIs(t, err, ErrVoided)
+
+	res, err = f.Authorize(ctx, AuthorizeRequest{AmountCent: 1500, Currency: "EUR", PaymentMethod: "fake_card"})
+	require.NoError(t, err)
+
+	_, err = f.Capture(ctx, res.Reference, 1500)
+	require.NoError(t, err)
+
+	_, err = f.Void(ctx, res.Reference)
+	assert.ErrorIs(t, err, ErrAlreadyCaptured)
+
+}
+
+func TestFakeDeclines(t *testing.T) {
+
+	f := NewFake("secret")
+
+	_, err := f.Authorize(context.Background(), AuthorizeRequest{AmountCent: 1500, PaymentMethod: FakeDeclinedMethod})
+	assert.ErrorIs(t, err, ErrDeclined)
+
+	_, err = f.Authorize(context.Background(), AuthorizeRequest{AmountCent: 0, PaymentMethod: "fake_card"})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+}
+
+func TestFakeVerifyWebhook(t *testing.T) {
+
+	f := NewFake("secret")
+	payload := []byte(`{"id":"evt_1","type":"payment.captured","reference":"fake_auth_1","amount_cent":1500}`)
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, f.Sign(payload))
+
+	event, err := f.VerifyWebhook(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, EventCaptured, event.Type)
+	assert.Equal(t, int64(1500), event.AmountCent)
+
+	header.Set(FakeSignatureHeader, NewFake("other").Sign(payload))
+	_, err = f.VerifyWebhook(payload, header)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+}
@@ -0,0 +1,57 @@
+// Package payments contains the payment provider abstraction used by the
+// checkout flow. Amounts are always integer cents.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrDeclined         = errors.New("payment declined")
+	ErrInvalidAmount    = errors.New("invalid payment amount")
+	ErrUnknownReference = errors.New("unknown payment reference")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrAlreadyCaptured  = errors.New("payment was already captured")
+	ErrVoided           = errors.New("payment authorization was voided")
+)
+
+const (
+	EventCaptured = "payment.captured"
+	EventFailed   = "payment.failed"
+	EventRefunded = "payment.refunded"
+)
+
+type AuthorizeRequest struct {
+	AmountCent    int64
+	Currency      string
+	PaymentMethod string
+	// OrderReference is sent to the provider so payments can be matched with orders
+	OrderReference string
+}
+
+type Result struct {
+	Reference  string
+	AmountCent int64
+}
+
+type WebhookEvent struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Reference  string `json:"reference"`
+	AmountCent int64  `json:"amount_cent"`
+}
+
+// Provider is implemented by every payment gateway. Authorize holds the amount
+// on the customer's payment method, Capture takes (part of) the held amount,
+// Void releases a hold nothing was captured from and Refund gives (part of) a
+// captured amount back.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, reference string, amountCent int64) (*Result, error)
+	Void(ctx context.Context, reference string) (*Result, error)
+	Refund(ctx context.Context, reference string, amountCent int64) (*Result, error)
+	VerifyWebhook(payload []byte, header http.Header) (*WebhookEvent, error)
+}
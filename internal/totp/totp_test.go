@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFCVectors(t *testing.T) {
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+
+}
+
+func TestValidate(t *testing.T) {
+
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+
+	code, err := Code(secret, Step(now))
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// a code can't be replayed once its step was used
+	_, ok = Validate(secret, code, now, step)
+	assert.False(t, ok)
+
+	// one period of drift is fine, two are not
+	_, ok = Validate(secret, code, now.Add(Period*time.Second), 0)
+	assert.True(t, ok)
+
+	_, ok = Validate(secret, code, now.Add(2*Period*time.Second), 0)
+	assert.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 0)
+	assert.False(t, ok)
+
+}
+
+func TestURI(t *testing.T) {
+
+	uri := URI("restaurantAPI", "jane@example.com", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/restaurantAPI:jane@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=restaurantAPI")
+
+}
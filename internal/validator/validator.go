@@ -44,3 +44,13 @@ func (v *Validator) Empty(value string) bool {
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
 }
+
+func Unique[T comparable](values []T) bool {
+	uniqueValues := make(map[T]bool, len(values))
+
+	for _, value := range values {
+		uniqueValues[value] = true
+	}
+
+	return len(values) == len(uniqueValues)
+}